@@ -0,0 +1,206 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+)
+
+// NetworkType is the first byte of an address, it tells which network the address belongs to.
+type NetworkType uint8
+
+// Network types of the Sirius / NEM Catapult networks.
+const (
+	NotSupportedNet NetworkType = 0
+	Mijin           NetworkType = 0x60
+	MijinTest       NetworkType = 0x90
+	MainNet         NetworkType = 0xb8
+	TestNet         NetworkType = 0xa8
+	Private         NetworkType = 0xc8
+	PrivateTest     NetworkType = 0xb0
+)
+
+const (
+	// AddressSize is the size of a decoded address: network type(1) | RIPEMD-160 hash(20) | checksum(4).
+	AddressSize = 25
+	// AddressEncodedSize is the size of a Base32 encoded address.
+	AddressEncodedSize = 40
+	// addressChecksumSize is the size of the checksum at the end of an address.
+	addressChecksumSize = 4
+	// addressPrettyGroupSize is the number of characters between dashes in the pretty form.
+	addressPrettyGroupSize = 6
+)
+
+var (
+	// ErrInvalidAddress is returned when an address is not 40 Base32 characters.
+	ErrInvalidAddress = errors.New("address must be 40 Base32 characters")
+	// ErrInvalidAddressChecksum is returned when the checksum of an address does not match.
+	ErrInvalidAddressChecksum = errors.New("address checksum is not valid")
+	// ErrUnknownNetworkType is returned when an address has a network type this library does not know.
+	ErrUnknownNetworkType = errors.New("unknown network type")
+)
+
+var networkTypeNames = map[NetworkType]string{
+	Mijin:       "Mijin",
+	MijinTest:   "MijinTest",
+	MainNet:     "MainNet",
+	TestNet:     "TestNet",
+	Private:     "Private",
+	PrivateTest: "PrivateTest",
+}
+
+func (ref NetworkType) String() string {
+
+	if name, ok := networkTypeNames[ref]; ok {
+		return name
+	}
+
+	return "NotSupportedNet"
+}
+
+// Address is the address of an account on a network.
+type Address struct {
+	raw []byte
+}
+
+// NewAddressFromPublicKey derives the address of publicKey on network:
+// network type | RIPEMD-160(SHA3-256(public key)) | checksum,
+// where checksum is the first 4 bytes of SHA3-256 of the preceding 21 bytes.
+func NewAddressFromPublicKey(publicKey *PublicKey, network NetworkType) (*Address, error) {
+
+	if _, ok := networkTypeNames[network]; !ok {
+		return nil, ErrUnknownNetworkType
+	}
+	if len(publicKey.Raw) != 32 {
+		return nil, ErrInvalidSizePublicKey
+	}
+
+	hash, err := HashesSha3_256(publicKey.Raw)
+	if err != nil {
+		return nil, err
+	}
+	hash, err = HashesRipemd160(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 0, AddressSize)
+	raw = append(raw, byte(network))
+	raw = append(raw, hash...)
+	checksum, err := addressChecksum(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Address{append(raw, checksum...)}, nil
+}
+
+// NewAddressFromBytes creates an address from its 25 bytes and validates it.
+func NewAddressFromBytes(b []byte) (*Address, error) {
+
+	if len(b) != AddressSize {
+		return nil, ErrInvalidAddress
+	}
+	if _, ok := networkTypeNames[NetworkType(b[0])]; !ok {
+		return nil, ErrUnknownNetworkType
+	}
+	checksum, err := addressChecksum(b[:AddressSize-addressChecksumSize])
+	if err != nil {
+		return nil, err
+	}
+	if !isEqualConstantTime(checksum, b[AddressSize-addressChecksumSize:]) {
+		return nil, ErrInvalidAddressChecksum
+	}
+
+	raw := make([]byte, AddressSize)
+	copy(raw, b)
+
+	return &Address{raw}, nil
+}
+
+// NewAddressFromString parses an address in the plain or the pretty form, case insensitive, and validates it.
+func NewAddressFromString(address string) (*Address, error) {
+
+	address = strings.ToUpper(strings.Replace(strings.TrimSpace(address), "-", "", -1))
+	if len(address) != AddressEncodedSize {
+		return nil, ErrInvalidAddress
+	}
+
+	raw, err := base32.StdEncoding.DecodeString(address)
+	if err != nil {
+		return nil, ErrInvalidAddress
+	}
+
+	return NewAddressFromBytes(raw)
+}
+
+// ValidateAddress checks that address in the plain or the pretty form is a valid address.
+func ValidateAddress(address string) error {
+
+	_, err := NewAddressFromString(address)
+	return err
+}
+
+// NetworkType returns the network the address belongs to.
+func (ref *Address) NetworkType() NetworkType {
+
+	return NetworkType(ref.raw[0])
+}
+
+// Bytes returns the 25 bytes of the address.
+func (ref *Address) Bytes() []byte {
+
+	b := make([]byte, len(ref.raw))
+	copy(b, ref.raw)
+	return b
+}
+
+// String returns the 40 characters Base32 form of the address.
+func (ref *Address) String() string {
+
+	return base32.StdEncoding.EncodeToString(ref.raw)
+}
+
+// Pretty returns the Base32 form of the address split by dashes in groups of 6 characters.
+func (ref *Address) Pretty() string {
+
+	plain := ref.String()
+	var b strings.Builder
+	for i := 0; i < len(plain); i += addressPrettyGroupSize {
+		if i > 0 {
+			b.WriteString("-")
+		}
+		end := i + addressPrettyGroupSize
+		if end > len(plain) {
+			end = len(plain)
+		}
+		b.WriteString(plain[i:end])
+	}
+
+	return b.String()
+}
+
+// IsDerivedFrom reports whether the address is the address of publicKey on its network.
+func (ref *Address) IsDerivedFrom(publicKey *PublicKey) bool {
+
+	address, err := NewAddressFromPublicKey(publicKey, ref.NetworkType())
+	if err != nil {
+		return false
+	}
+
+	return isEqualConstantTime(address.raw, ref.raw)
+}
+
+func addressChecksum(b []byte) ([]byte, error) {
+
+	hash, err := HashesSha3_256(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash[:addressChecksumSize], nil
+}
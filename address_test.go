@@ -0,0 +1,98 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const addressTestPublicKey = "C5FB65CB902623D93DF2E682FFB13F99D50FAC24D5FF2A42F68C7CA1772FE8A0"
+
+var addressVectors = map[NetworkType]string{
+	Mijin:       "MBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNKPLWM22",
+	MijinTest:   "SBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNL45KPBP",
+	MainNet:     "XBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNJBHBRNB",
+	TestNet:     "VBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNLM6Z7HS",
+	Private:     "ZBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNL5XUFOP",
+	PrivateTest: "WBLYH55IHPS5QCCMNWR3GZWKV6WMCKPTNI2FRPPQ",
+}
+
+func TestNewAddressFromPublicKey(t *testing.T) {
+	publicKey, err := NewPublicKeyfromHex(addressTestPublicKey)
+	assert.Nil(t, err)
+
+	for network, expected := range addressVectors {
+		address, err := NewAddressFromPublicKey(publicKey, network)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, address.String(), network.String())
+		assert.Equal(t, network, address.NetworkType())
+		assert.Len(t, address.Bytes(), AddressSize)
+		assert.True(t, address.IsDerivedFrom(publicKey))
+	}
+
+	_, err = NewAddressFromPublicKey(publicKey, NotSupportedNet)
+	assert.Equal(t, ErrUnknownNetworkType, err)
+	_, err = NewAddressFromPublicKey(NewPublicKey(make([]byte, 31)), MainNet)
+	assert.Equal(t, ErrInvalidSizePublicKey, err)
+}
+
+func TestAddress_Pretty(t *testing.T) {
+	address, err := NewAddressFromString(addressVectors[MainNet])
+	assert.Nil(t, err)
+
+	pretty := address.Pretty()
+
+	assert.Equal(t, "XBLYH5-5IHPS5-QCCMNW-R3GZWK-V6WMCK-PTNJBH-BRNB", pretty)
+	parsed, err := NewAddressFromString(pretty)
+	assert.Nil(t, err)
+	assert.Equal(t, address, parsed)
+}
+
+func TestNewAddressFromString(t *testing.T) {
+	address, err := NewAddressFromString(" " + strings.ToLower(addressVectors[TestNet]) + "\n")
+	assert.Nil(t, err)
+	assert.Equal(t, addressVectors[TestNet], address.String())
+
+	restored, err := NewAddressFromBytes(address.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, address, restored)
+}
+
+func TestValidateAddress(t *testing.T) {
+	for _, address := range addressVectors {
+		assert.Nil(t, ValidateAddress(address))
+	}
+
+	valid := addressVectors[MainNet]
+	assert.Equal(t, ErrInvalidAddress, ValidateAddress(valid[:39]))
+	assert.Equal(t, ErrInvalidAddress, ValidateAddress(valid+"A"))
+	assert.Equal(t, ErrInvalidAddress, ValidateAddress("1"+valid[1:]))
+	assert.Equal(t, ErrInvalidAddressChecksum, ValidateAddress(valid[:39]+"A"))
+	assert.Equal(t, ErrInvalidAddressChecksum, ValidateAddress(valid[:10]+"A"+valid[11:]))
+	// the network type byte 0x00 starts with "A"
+	assert.Equal(t, ErrUnknownNetworkType, ValidateAddress("A"+valid[1:]))
+
+	_, err := NewAddressFromBytes(make([]byte, AddressSize-1))
+	assert.Equal(t, ErrInvalidAddress, err)
+}
+
+func TestAddress_IsDerivedFromOtherKey(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	address, err := NewAddressFromString(addressVectors[MainNet])
+	assert.Nil(t, err)
+
+	assert.False(t, address.IsDerivedFrom(kp.PublicKey))
+}
+
+func TestNetworkType_String(t *testing.T) {
+	assert.Equal(t, "MainNet", MainNet.String())
+	assert.Equal(t, "Mijin", Mijin.String())
+	assert.Equal(t, "NotSupportedNet", NetworkType(1).String())
+}
@@ -4,40 +4,183 @@
 
 package crypto
 
-// BufferedBlockCipher use to copy bytes with wrapped aes key
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// The types of this file port the BouncyCastle buffered block cipher API used by the Java SDK:
+//
+//	cipher := NewPaddedBufferedBlockCipher(NewCBCBlockCipher(NewAESEngine()), NewPKCS7Padding())
+//	err := cipher.Init(true, NewParametersWithIV(NewKeyParameter(key), iv))
+//	out := make([]byte, cipher.GetOutputSize(len(input)))
+//	n, err := cipher.ProcessBytes(input, 0, len(input), out, 0)
+//	m, err := cipher.DoFinal(out, n)
+//	out = out[:n+m]
+//
+// Errors are returned where BouncyCastle throws exceptions.
+
+var (
+	// ErrCipherNotInitialised is returned when a cipher is used before Init.
+	ErrCipherNotInitialised = errors.New("cipher is not initialised")
+	// ErrOutputBufferTooShort is returned when the output buffer cannot hold the result.
+	ErrOutputBufferTooShort = errors.New("output buffer is too short")
+	// ErrInputBufferTooShort is returned when the input buffer has less data than requested.
+	ErrInputBufferTooShort = errors.New("input buffer is too short")
+	// ErrIncompleteBlock is returned when the decrypted data is not a multiple of the block size.
+	ErrIncompleteBlock = errors.New("last block is incomplete in decryption")
+	// ErrInvalidCipherParameters is returned when the key or the IV has an invalid size.
+	ErrInvalidCipherParameters = errors.New("invalid key or IV size")
+)
+
+// BufferedBlockCipher buffers input until a whole block can be processed
+// and pads the last block (PaddedBufferedBlockCipher in BouncyCastle).
 type BufferedBlockCipher struct {
-	output  []byte
-	block   *CBCBlockCipher
-	padding *PKCS7Padding
+	buf           []byte
+	bufOff        int
+	forEncryption bool
+	block         *CBCBlockCipher
+	padding       *PKCS7Padding
 }
 
 // NewPaddedBufferedBlockCipher create new BufferedBlockCipher
 func NewPaddedBufferedBlockCipher(block *CBCBlockCipher, padding *PKCS7Padding) *BufferedBlockCipher {
-	return &BufferedBlockCipher{nil, block, padding}
+	return &BufferedBlockCipher{make([]byte, block.BlockSize()), 0, false, block, padding}
 }
 
-// GetOutputSize return size output buffer
-func (ref *BufferedBlockCipher) GetOutputSize(length int) int {
-	return len(ref.output)
+// Init initialises the cipher for encryption or decryption with a key and an IV.
+func (ref *BufferedBlockCipher) Init(forEncryption bool, params *CipherParameters) error {
+	ref.forEncryption = forEncryption
+	ref.Reset()
+
+	return ref.block.Init(forEncryption, params)
 }
 
-func (ref *BufferedBlockCipher) reset() {
-	ref.output = nil
+// GetOutputSize return size output buffer that ProcessBytes and DoFinal need for length more bytes of input
+func (ref *BufferedBlockCipher) GetOutputSize(length int) int {
+	total := length + ref.bufOff
+	leftOver := total % len(ref.buf)
+	if leftOver == 0 {
+		if ref.forEncryption {
+			return total + len(ref.buf)
+		}
+		return total
+	}
+
+	return total - leftOver + len(ref.buf)
 }
 
-func (ref *BufferedBlockCipher) init(forEncryption bool, params *CipherParameters) {
+// GetUpdateOutputSize returns the size of the output ProcessBytes writes for length more bytes of input.
+func (ref *BufferedBlockCipher) GetUpdateOutputSize(length int) int {
+	total := length + ref.bufOff
+	leftOver := total % len(ref.buf)
+	if leftOver == 0 {
+		// the last block is kept until DoFinal, it may have to be unpadded
+		if total < len(ref.buf) {
+			return 0
+		}
+		return total - len(ref.buf)
+	}
+
+	return total - leftOver
+}
 
+// Reset clears the buffered input and restores the IV.
+func (ref *BufferedBlockCipher) Reset() {
+	for i := range ref.buf {
+		ref.buf[i] = 0
+	}
+	ref.bufOff = 0
+	ref.block.Reset()
 }
 
-func (ref *BufferedBlockCipher) doFinal(out []byte, outOff int) int {
-	return copy(out, ref.output)
+// ProcessBytes processes length bytes of input from inOff and writes the output to out from outOff.
+// It returns the number of bytes written.
+func (ref *BufferedBlockCipher) ProcessBytes(input []byte, inOff, length int, out []byte, outOff int) (int, error) {
+	if inOff < 0 || length < 0 || inOff+length > len(input) {
+		return 0, ErrInputBufferTooShort
+	}
+	if outLength := ref.GetUpdateOutputSize(length); outLength > 0 && outOff+outLength > len(out) {
+		return 0, ErrOutputBufferTooShort
+	}
+
+	blockSize := len(ref.buf)
+	resultLen := 0
+	gapLen := blockSize - ref.bufOff
+	if length > gapLen {
+		copy(ref.buf[ref.bufOff:], input[inOff:inOff+gapLen])
+		n, err := ref.block.ProcessBlock(ref.buf, 0, out, outOff)
+		if err != nil {
+			return 0, err
+		}
+		resultLen += n
+		ref.bufOff = 0
+		length -= gapLen
+		inOff += gapLen
+
+		for length > blockSize {
+			n, err = ref.block.ProcessBlock(input, inOff, out, outOff+resultLen)
+			if err != nil {
+				return 0, err
+			}
+			resultLen += n
+			length -= blockSize
+			inOff += blockSize
+		}
+	}
+	copy(ref.buf[ref.bufOff:], input[inOff:inOff+length])
+	ref.bufOff += length
+
+	return resultLen, nil
 }
 
-func (ref *BufferedBlockCipher) processBytes(input []byte, inOff, length int, out []byte, outOff int) int {
-	if len(out) < length-inOff {
-		return -1
+// DoFinal processes the buffered input, adds or removes the padding and resets the cipher.
+// It returns the number of bytes written to out from outOff.
+func (ref *BufferedBlockCipher) DoFinal(out []byte, outOff int) (int, error) {
+	defer ref.Reset()
+
+	blockSize := len(ref.buf)
+	resultLen := 0
+	if ref.forEncryption {
+		if ref.bufOff == blockSize {
+			if outOff+2*blockSize > len(out) {
+				return 0, ErrOutputBufferTooShort
+			}
+			n, err := ref.block.ProcessBlock(ref.buf, 0, out, outOff)
+			if err != nil {
+				return 0, err
+			}
+			resultLen += n
+			ref.bufOff = 0
+		}
+		ref.padding.AddPadding(ref.buf, ref.bufOff)
+		n, err := ref.block.ProcessBlock(ref.buf, 0, out, outOff+resultLen)
+		if err != nil {
+			return 0, err
+		}
+
+		return resultLen + n, nil
+	}
+
+	if ref.bufOff != blockSize {
+		return 0, ErrIncompleteBlock
+	}
+	n, err := ref.block.ProcessBlock(ref.buf, 0, ref.buf, 0)
+	if err != nil {
+		return 0, err
 	}
-	return copy(input, out)
+	padCount, err := ref.padding.PadCount(ref.buf)
+	if err != nil {
+		return 0, err
+	}
+	resultLen = n - padCount
+	if outOff+resultLen > len(out) {
+		return 0, ErrOutputBufferTooShort
+	}
+
+	return copy(out[outOff:], ref.buf[:resultLen]), nil
 }
 
 // KeyParameter has buffer bytes
@@ -50,32 +193,180 @@ func NewKeyParameter(buf []byte) *KeyParameter {
 	return &KeyParameter{buf}
 }
 
+// CipherParameters are the key and the IV a cipher is initialised with.
 type CipherParameters struct {
 	keyParam *KeyParameter
 	buf      []byte
 }
 
+// NewParametersWithIV creates the parameters of a key and an IV.
 func NewParametersWithIV(keyParam *KeyParameter, buf []byte) *CipherParameters {
 	return &CipherParameters{keyParam, buf}
 }
 
+// PKCS7Padding pads the last block with n bytes of value n.
 type PKCS7Padding struct {
 }
 
+// NewPKCS7Padding creates PKCS7Padding
 func NewPKCS7Padding() *PKCS7Padding {
 	return &PKCS7Padding{}
 }
 
+// AddPadding pads block from inOff to its end and returns the number of added bytes.
+func (ref *PKCS7Padding) AddPadding(block []byte, inOff int) int {
+	code := byte(len(block) - inOff)
+	for i := inOff; i < len(block); i++ {
+		block[i] = code
+	}
+
+	return int(code)
+}
+
+// PadCount returns the number of padding bytes of block.
+// Every padding failure returns ErrDecryptionFailed and all bytes of the block are checked.
+func (ref *PKCS7Padding) PadCount(block []byte) (int, error) {
+	count := int(block[len(block)-1])
+	good := subtle.ConstantTimeLessOrEq(1, count) & subtle.ConstantTimeLessOrEq(count, len(block))
+	for i := 1; i <= len(block); i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, count)
+		equal := subtle.ConstantTimeByteEq(block[len(block)-i], byte(count))
+		good &= subtle.ConstantTimeSelect(inPadding, equal, 1)
+	}
+	if good != 1 {
+		return 0, ErrDecryptionFailed
+	}
+
+	return count, nil
+}
+
+// CBCBlockCipher chains the blocks of AESEngine in CBC mode.
 type CBCBlockCipher struct {
-	aes *AESEngine
+	aes           *AESEngine
+	iv            []byte
+	cbcV          []byte
+	forEncryption bool
 }
 
+// NewCBCBlockCipher creates CBCBlockCipher
 func NewCBCBlockCipher(aes *AESEngine) *CBCBlockCipher {
-	return &CBCBlockCipher{aes}
+	return &CBCBlockCipher{aes: aes}
+}
+
+// BlockSize returns the block size of the cipher.
+func (ref *CBCBlockCipher) BlockSize() int {
+	return ref.aes.BlockSize()
+}
+
+// Init initialises the cipher with a key and an IV, if the IV is nil - use a zero IV.
+func (ref *CBCBlockCipher) Init(forEncryption bool, params *CipherParameters) error {
+	if params == nil || params.keyParam == nil {
+		return ErrInvalidCipherParameters
+	}
+
+	iv := params.buf
+	if iv == nil {
+		iv = make([]byte, ref.BlockSize())
+	}
+	if len(iv) != ref.BlockSize() {
+		return ErrInvalidCipherParameters
+	}
+	ref.iv = append([]byte{}, iv...)
+	ref.cbcV = append([]byte{}, iv...)
+	ref.forEncryption = forEncryption
+
+	return ref.aes.Init(forEncryption, params.keyParam)
+}
+
+// Reset restores the IV.
+func (ref *CBCBlockCipher) Reset() {
+	copy(ref.cbcV, ref.iv)
+}
+
+// ProcessBlock encrypts or decrypts one block of input from inOff to out from outOff.
+func (ref *CBCBlockCipher) ProcessBlock(input []byte, inOff int, out []byte, outOff int) (int, error) {
+	if ref.cbcV == nil {
+		return 0, ErrCipherNotInitialised
+	}
+	blockSize := ref.BlockSize()
+	if inOff+blockSize > len(input) {
+		return 0, ErrInputBufferTooShort
+	}
+	if outOff+blockSize > len(out) {
+		return 0, ErrOutputBufferTooShort
+	}
+
+	if ref.forEncryption {
+		for i := 0; i < blockSize; i++ {
+			ref.cbcV[i] ^= input[inOff+i]
+		}
+		n, err := ref.aes.ProcessBlock(ref.cbcV, 0, out, outOff)
+		if err != nil {
+			return 0, err
+		}
+		copy(ref.cbcV, out[outOff:outOff+blockSize])
+		return n, nil
+	}
+
+	// input and out may be the same buffer
+	next := append([]byte{}, input[inOff:inOff+blockSize]...)
+	n, err := ref.aes.ProcessBlock(input, inOff, out, outOff)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < blockSize; i++ {
+		out[outOff+i] ^= ref.cbcV[i]
+	}
+	copy(ref.cbcV, next)
+
+	return n, nil
 }
 
-type AESEngine struct{}
+// AESEngine encrypts or decrypts single AES blocks.
+type AESEngine struct {
+	block         cipher.Block
+	forEncryption bool
+}
 
+// NewAESEngine creates AESEngine
 func NewAESEngine() *AESEngine {
 	return &AESEngine{}
 }
+
+// BlockSize returns the AES block size.
+func (ref *AESEngine) BlockSize() int {
+	return aes.BlockSize
+}
+
+// Init initialises the engine with a 16, 24 or 32 bytes key.
+func (ref *AESEngine) Init(forEncryption bool, key *KeyParameter) error {
+	block, err := aes.NewCipher(key.buf)
+	if err != nil {
+		return ErrInvalidCipherParameters
+	}
+	ref.block = block
+	ref.forEncryption = forEncryption
+
+	return nil
+}
+
+// ProcessBlock encrypts or decrypts one block of input from inOff to out from outOff.
+func (ref *AESEngine) ProcessBlock(input []byte, inOff int, out []byte, outOff int) (int, error) {
+	if ref.block == nil {
+		return 0, ErrCipherNotInitialised
+	}
+	if inOff+aes.BlockSize > len(input) {
+		return 0, ErrInputBufferTooShort
+	}
+	if outOff+aes.BlockSize > len(out) {
+		return 0, ErrOutputBufferTooShort
+	}
+
+	if ref.forEncryption {
+		ref.block.Encrypt(out[outOff:outOff+aes.BlockSize], input[inOff:inOff+aes.BlockSize])
+	} else {
+		ref.block.Decrypt(out[outOff:outOff+aes.BlockSize], input[inOff:inOff+aes.BlockSize])
+	}
+
+	return aes.BlockSize, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBufferedBlockCipher(t *testing.T, forEncryption bool, key, iv []byte) *BufferedBlockCipher {
+	blockCipher := NewPaddedBufferedBlockCipher(NewCBCBlockCipher(NewAESEngine()), NewPKCS7Padding())
+	assert.Nil(t, blockCipher.Init(forEncryption, NewParametersWithIV(NewKeyParameter(key), iv)))
+
+	return blockCipher
+}
+
+func processAll(t *testing.T, blockCipher *BufferedBlockCipher, input []byte, chunk int) []byte {
+	out := make([]byte, blockCipher.GetOutputSize(len(input)))
+	outOff := 0
+	for inOff := 0; inOff < len(input); inOff += chunk {
+		length := chunk
+		if inOff+length > len(input) {
+			length = len(input) - inOff
+		}
+		n, err := blockCipher.ProcessBytes(input, inOff, length, out, outOff)
+		assert.Nil(t, err)
+		outOff += n
+	}
+	n, err := blockCipher.DoFinal(out, outOff)
+	assert.Nil(t, err)
+
+	return out[:outOff+n]
+}
+
+func TestBufferedBlockCipher_NistVector(t *testing.T) {
+	// NIST SP 800-38A F.2.5 CBC-AES256.Encrypt, followed by the PKCS#7 padding block
+	key, _ := hex.DecodeString("603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4")
+	iv, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	plain, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	expected := "f58c4c04d6e5f1ba779eabfb5f7bfbd69cfc4e967edb808d679f777bc6702c7d" +
+		"39f23369a9d9bacfa530e26304231461b2eb05e2c39be9fcda6c19078c6a9d1b"
+
+	for _, chunk := range []int{1, 7, 16, 17, len(plain)} {
+		encrypted := processAll(t, newTestBufferedBlockCipher(t, true, key, iv), plain, chunk)
+		assert.Len(t, encrypted, len(plain)+16)
+		assert.Equal(t, expected, hex.EncodeToString(encrypted[:len(plain)]))
+
+		decrypted := processAll(t, newTestBufferedBlockCipher(t, false, key, iv), encrypted, chunk)
+		assert.Equal(t, plain, decrypted)
+	}
+}
+
+func TestBufferedBlockCipher_MatchesBlockCipherEncoding(t *testing.T) {
+	key := make([]byte, 32)
+	iv := make([]byte, 16)
+	_, err := rand.Read(key)
+	assert.Nil(t, err)
+	_, err = rand.Read(iv)
+	assert.Nil(t, err)
+	blockCipher := &Ed25519BlockCipher{}
+
+	for _, size := range []int{0, 1, 15, 16, 33} {
+		input := make([]byte, size)
+		_, err := rand.Read(input)
+		assert.Nil(t, err)
+
+		encrypted := processAll(t, newTestBufferedBlockCipher(t, true, key, iv), input, 5)
+		expected, err := blockCipher.encode(input, key, iv)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, encrypted)
+
+		decrypted := processAll(t, newTestBufferedBlockCipher(t, false, key, iv), encrypted, 5)
+		assert.Equal(t, input, decrypted)
+	}
+}
+
+func TestBufferedBlockCipher_ReuseAfterDoFinal(t *testing.T) {
+	key := make([]byte, 16)
+	iv := make([]byte, 16)
+	blockCipher := newTestBufferedBlockCipher(t, true, key, iv)
+
+	first := processAll(t, blockCipher, []byte(message), 3)
+	second := processAll(t, blockCipher, []byte(message), 3)
+
+	assert.Equal(t, first, second)
+}
+
+func TestBufferedBlockCipher_Errors(t *testing.T) {
+	key := make([]byte, 32)
+	iv := make([]byte, 16)
+	blockCipher := NewPaddedBufferedBlockCipher(NewCBCBlockCipher(NewAESEngine()), NewPKCS7Padding())
+
+	_, err := blockCipher.ProcessBytes(make([]byte, 32), 0, 32, make([]byte, 32), 0)
+	assert.Equal(t, ErrCipherNotInitialised, err)
+	assert.Equal(t, ErrInvalidCipherParameters, blockCipher.Init(true, NewParametersWithIV(NewKeyParameter(key[:31]), iv)))
+	assert.Equal(t, ErrInvalidCipherParameters, blockCipher.Init(true, NewParametersWithIV(NewKeyParameter(key), iv[:8])))
+
+	blockCipher = newTestBufferedBlockCipher(t, true, key, iv)
+	_, err = blockCipher.ProcessBytes(make([]byte, 40), 0, 40, make([]byte, 16), 0)
+	assert.Equal(t, ErrOutputBufferTooShort, err)
+	_, err = blockCipher.ProcessBytes(make([]byte, 8), 4, 8, make([]byte, 16), 0)
+	assert.Equal(t, ErrInputBufferTooShort, err)
+
+	blockCipher = newTestBufferedBlockCipher(t, false, key, iv)
+	_, err = blockCipher.ProcessBytes(make([]byte, 20), 0, 20, make([]byte, 32), 0)
+	assert.Nil(t, err)
+	_, err = blockCipher.DoFinal(make([]byte, 32), 0)
+	assert.Equal(t, ErrIncompleteBlock, err)
+
+	// the last byte of the previous block flips the last padding byte
+	encrypted := processAll(t, newTestBufferedBlockCipher(t, true, key, iv), []byte(message), 16)
+	encrypted[len(encrypted)-17] ^= 0x20
+	blockCipher = newTestBufferedBlockCipher(t, false, key, iv)
+	out := make([]byte, blockCipher.GetOutputSize(len(encrypted)))
+	n, err := blockCipher.ProcessBytes(encrypted, 0, len(encrypted), out, 0)
+	assert.Nil(t, err)
+	_, err = blockCipher.DoFinal(out, n)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestPKCS7Padding(t *testing.T) {
+	padding := NewPKCS7Padding()
+	block := make([]byte, 16)
+
+	assert.Equal(t, 16, padding.AddPadding(block, 0))
+	count, err := padding.PadCount(block)
+	assert.Nil(t, err)
+	assert.Equal(t, 16, count)
+
+	assert.Equal(t, 3, padding.AddPadding(block, 13))
+	count, err = padding.PadCount(block)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	for _, last := range []byte{0, 17, 0xff} {
+		block[15] = last
+		_, err = padding.PadCount(block)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+	block[15], block[14] = 3, 2
+	_, err = padding.PadCount(block)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
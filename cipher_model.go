@@ -5,14 +5,18 @@
 package crypto
 
 // NewBlockCipher creates a block cipher around a sender KeyPair and recipient KeyPair.
-// if engine is nil - use CryptoEngines.DefaultEngine instead
+// if engine is nil - use CryptoEngines.Default() instead
 // The sender KeyPair. The sender'S private key is required for encryption.
 // The recipient KeyPair. The recipient'S private key is required for decryption.
 func NewBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair, engine CryptoEngine) BlockCipher {
-	if engine == nil {
-		engine = CryptoEngines.DefaultEngine
-	}
-	return engine.CreateBlockCipher(senderKeyPair, recipientKeyPair)
+	return CryptoEngines.resolve(engine).CreateBlockCipher(senderKeyPair, recipientKeyPair)
+}
+
+// NewPasswordBlockCipher creates a block cipher that encrypts with a key derived from password, without key pairs.
+// if engine is nil - use CryptoEngines.Default() instead, the salts and nonces are read from the seed of the engine
+// when it implements SeededEngine and from crypto/rand otherwise.
+func NewPasswordBlockCipher(password []byte, engine CryptoEngine) *PasswordBlockCipher {
+	return NewPasswordBlockCipherWithSeed(password, engineSeed(CryptoEngines.resolve(engine)))
 }
 
 // BlockCipher Interface for encryption and decryption of data.
@@ -22,3 +26,30 @@ type BlockCipher interface {
 	// Decrypts an arbitrarily-sized message.
 	Decrypt(input []byte) ([]byte, error)
 }
+
+// AeadBlockCipher is a BlockCipher that authenticates associated data together with the message.
+type AeadBlockCipher interface {
+	BlockCipher
+	// Encrypts an arbitrarily-sized message (input) and authenticates associatedData.
+	EncryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error)
+	// Decrypts an arbitrarily-sized message that was encrypted with the same associatedData.
+	DecryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error)
+}
+
+// EnvelopeBlockCipher is a BlockCipher that encrypts a message once for many recipients.
+type EnvelopeBlockCipher interface {
+	BlockCipher
+	// Encrypts an arbitrarily-sized message (input) for every one of recipients.
+	EncryptEnvelope(input []byte, recipients []*PublicKey) ([]byte, error)
+	// Decrypts an envelope with the slot of the recipient.
+	DecryptEnvelope(input []byte) ([]byte, error)
+}
+
+// AnonymousBlockCipher is a BlockCipher that encrypts to the recipient with an ephemeral sender key.
+type AnonymousBlockCipher interface {
+	BlockCipher
+	// Encrypts an arbitrarily-sized message (input) without the sender private key.
+	EncryptAnonymous(input []byte) ([]byte, error)
+	// Decrypts a message encrypted with EncryptAnonymous with the recipient private key.
+	DecryptAnonymous(input []byte) ([]byte, error)
+}
@@ -6,6 +6,7 @@ package crypto
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/subtle"
 
 	"golang.org/x/crypto/ripemd160"
@@ -23,6 +24,20 @@ func HashesSha_256(b []byte) ([]byte, error) {
 	return hash.Sum(nil), nil
 }
 
+// HashesSha_512 return Sha 512 hash of bytes
+func HashesSha_512(inputs ...[]byte) ([]byte, error) {
+	hash := sha512.New()
+	for _, b := range inputs {
+
+		_, err := hash.Write(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hash.Sum(nil), nil
+}
+
 // HashesKeccak_256 return Keccak 256 hash of byte
 func HashesKeccak_256(b []byte) ([]byte, error) {
 	hash := sha3.NewLegacyKeccak256()
@@ -59,6 +74,20 @@ func HashesSha3_512(inputs ...[]byte) ([]byte, error) {
 	return hash.Sum(nil), nil
 }
 
+// HashesKeccak_512 return Keccak 512 hash of bytes
+func HashesKeccak_512(inputs ...[]byte) ([]byte, error) {
+	hash := sha3.NewLegacyKeccak512()
+	for _, b := range inputs {
+
+		_, err := hash.Write(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hash.Sum(nil), nil
+}
+
 // HashesRipemd160  return ripemd160 hash of byte
 func HashesRipemd160(b []byte) ([]byte, error) {
 	hash := ripemd160.New()
@@ -4,6 +4,14 @@
 
 package crypto
 
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
 // CryptoEngine represents a cryptographic engine that is a factory of crypto-providers.
 type CryptoEngine interface {
 	// Creates a DSA signer.
@@ -14,16 +22,180 @@ type CryptoEngine interface {
 	CreateBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair) BlockCipher
 	// Creates a key analyzer.
 	CreateKeyAnalyzer() KeyAnalyzer
+	// Gets the underlying curve.
+	GetCurve() Curve
+}
+
+// SeededEngine is implemented by engines that read key material, salts and nonces from a seed.
+// Constructors that take an engine instead of a seed read from the seed of a SeededEngine,
+// so a third-party engine with a deterministic seed stays reproducible.
+type SeededEngine interface {
+	// Seed returns the seed of the engine, nil means crypto/rand.
+	Seed() io.Reader
 }
 
+// Names of the built-in crypto engines.
+const (
+	Ed25519Sha3EngineName   = "ed25519-sha3"
+	Ed25519Sha512EngineName = "ed25519-sha512"
+	Ed25519KeccakEngineName = "ed25519-keccak"
+)
+
+var (
+	// ErrUnknownCryptoEngine is returned when no engine is registered under the name.
+	ErrUnknownCryptoEngine = errors.New("crypto engine is not registered")
+	// ErrCryptoEngineAlreadyRegistered is returned when an engine is registered under a name that is taken.
+	ErrCryptoEngineAlreadyRegistered = errors.New("crypto engine with this name is already registered")
+	// ErrInvalidCryptoEngine is returned when an engine is registered without a name or as nil,
+	// a nil pointer of an engine type included.
+	ErrInvalidCryptoEngine = errors.New("crypto engine must have a name and must not be nil")
+)
+
 // cryptoEngines Static class that exposes crypto engines.
+// Besides the built-in engines it is a registry where engines are looked up by name,
+// so third-party engines can be plugged in and the default engine can be picked from configuration.
 type cryptoEngines struct {
-	Ed25519Engine *Ed25519SeedCryptoEngine
+	Ed25519Engine       *Ed25519SeedCryptoEngine
+	Ed25519Sha512Engine *Ed25519Sha512SeedCryptoEngine
+	Ed25519KeccakEngine *Ed25519KeccakSeedCryptoEngine
+	// DefaultEngine is the built-in ed25519-sha3 engine, the default engine before the registry.
+	// It does not follow SetDefault: passing it to a constructor always selects ed25519-sha3.
+	//
+	// Deprecated: pass nil or use Default, which follow SetDefault.
 	DefaultEngine *Ed25519SeedCryptoEngine
+
+	mutex         sync.RWMutex
+	engines       map[string]CryptoEngine
+	defaultEngine CryptoEngine
+	defaultName   string
+}
+
+// CryptoEngines has cryptographic engines.
+// It is a pointer since it holds the registry, the fields are read the same way as before.
+var CryptoEngines = newCryptoEngines()
+
+func newCryptoEngines() *cryptoEngines {
+	ref := &cryptoEngines{
+		Ed25519Engine:       &Ed25519SeedCryptoEngine{nil},
+		Ed25519Sha512Engine: &Ed25519Sha512SeedCryptoEngine{nil},
+		Ed25519KeccakEngine: &Ed25519KeccakSeedCryptoEngine{nil},
+		engines:             make(map[string]CryptoEngine),
+	}
+	ref.DefaultEngine = ref.Ed25519Engine
+	ref.engines[Ed25519Sha3EngineName] = ref.Ed25519Engine
+	ref.engines[Ed25519Sha512EngineName] = ref.Ed25519Sha512Engine
+	ref.engines[Ed25519KeccakEngineName] = ref.Ed25519KeccakEngine
+	ref.defaultEngine = ref.Ed25519Engine
+	ref.defaultName = Ed25519Sha3EngineName
+
+	return ref
+}
+
+// Register adds engine to the registry under name.
+func (ref *cryptoEngines) Register(name string, engine CryptoEngine) error {
+	if name == "" || isNilEngine(engine) {
+		return ErrInvalidCryptoEngine
+	}
+
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	if _, ok := ref.engines[name]; ok {
+		return ErrCryptoEngineAlreadyRegistered
+	}
+	ref.engines[name] = engine
+
+	return nil
+}
+
+// Get returns the engine registered under name.
+func (ref *cryptoEngines) Get(name string) (CryptoEngine, error) {
+	ref.mutex.RLock()
+	defer ref.mutex.RUnlock()
+
+	engine, ok := ref.engines[name]
+	if !ok {
+		return nil, ErrUnknownCryptoEngine
+	}
+
+	return engine, nil
+}
+
+// Names returns the sorted names of all registered engines.
+func (ref *cryptoEngines) Names() []string {
+	ref.mutex.RLock()
+	defer ref.mutex.RUnlock()
+
+	names := make([]string, 0, len(ref.engines))
+	for name := range ref.engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
 }
 
-// CryptoEngines has cryptographic engines
-var CryptoEngines = cryptoEngines{
-	&Ed25519SeedCryptoEngine{nil},
-	&Ed25519SeedCryptoEngine{nil},
+// SetDefault makes the engine registered under name the default engine.
+func (ref *cryptoEngines) SetDefault(name string) error {
+	engine, err := ref.Get(name)
+	if err != nil {
+		return err
+	}
+
+	ref.mutex.Lock()
+	defer ref.mutex.Unlock()
+
+	ref.defaultEngine = engine
+	ref.defaultName = name
+
+	return nil
+}
+
+// Default returns the engine used when nil is passed as engine.
+func (ref *cryptoEngines) Default() CryptoEngine {
+	ref.mutex.RLock()
+	defer ref.mutex.RUnlock()
+
+	return ref.defaultEngine
+}
+
+// DefaultName returns the name the default engine is registered under.
+func (ref *cryptoEngines) DefaultName() string {
+	ref.mutex.RLock()
+	defer ref.mutex.RUnlock()
+
+	return ref.defaultName
+}
+
+// resolve returns engine, or the default engine if engine is nil
+func (ref *cryptoEngines) resolve(engine CryptoEngine) CryptoEngine {
+	if engine == nil {
+		return ref.Default()
+	}
+
+	return engine
+}
+
+// isNilEngine reports whether engine is nil or holds a nil pointer, map, slice, channel or func.
+func isNilEngine(engine CryptoEngine) bool {
+	if engine == nil {
+		return true
+	}
+
+	value := reflect.ValueOf(engine)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return value.IsNil()
+	}
+
+	return false
+}
+
+// engineSeed returns the seed of a SeededEngine, for other engines it returns nil.
+func engineSeed(engine CryptoEngine) io.Reader {
+	if seeded, ok := engine.(SeededEngine); ok {
+		return seeded.Seed()
+	}
+
+	return nil
 }
@@ -0,0 +1,115 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pluggableEngine is a third-party engine that delegates everything to the RFC 8032 engine.
+type pluggableEngine struct {
+	*Ed25519Sha512SeedCryptoEngine
+}
+
+func TestCryptoEngines_BuiltInEnginesAreRegistered(t *testing.T) {
+	assert.Equal(t, []string{Ed25519KeccakEngineName, Ed25519Sha3EngineName, Ed25519Sha512EngineName}, newCryptoEngines().Names())
+
+	engine, err := CryptoEngines.Get(Ed25519Sha3EngineName)
+	assert.Nil(t, err)
+	assert.Equal(t, CryptoEngines.Ed25519Engine, engine)
+	engine, err = CryptoEngines.Get(Ed25519Sha512EngineName)
+	assert.Nil(t, err)
+	assert.Equal(t, CryptoEngines.Ed25519Sha512Engine, engine)
+	engine, err = CryptoEngines.Get(Ed25519KeccakEngineName)
+	assert.Nil(t, err)
+	assert.Equal(t, CryptoEngines.Ed25519KeccakEngine, engine)
+}
+
+func TestCryptoEngines_DefaultIsSha3Engine(t *testing.T) {
+	assert.Equal(t, CryptoEngines.Ed25519Engine, newCryptoEngines().Default())
+	// the field of the releases before the registry still compiles
+	var engine *Ed25519SeedCryptoEngine = CryptoEngines.DefaultEngine
+	assert.Equal(t, CryptoEngines.Ed25519Engine, engine)
+
+	// the deprecated field does not follow SetDefault
+	engines := newCryptoEngines()
+	assert.Nil(t, engines.SetDefault(Ed25519Sha512EngineName))
+	assert.Equal(t, engines.Ed25519Engine, engines.DefaultEngine)
+}
+
+func TestCryptoEngines_GetUnknownEngine(t *testing.T) {
+	_, err := CryptoEngines.Get("ed448")
+
+	assert.Equal(t, ErrUnknownCryptoEngine, err)
+	assert.Equal(t, ErrUnknownCryptoEngine, CryptoEngines.SetDefault("ed448"))
+}
+
+func TestCryptoEngines_RegisterThirdPartyEngine(t *testing.T) {
+	engines := newCryptoEngines()
+	custom := &pluggableEngine{&Ed25519Sha512SeedCryptoEngine{nil}}
+
+	assert.Nil(t, engines.Register("custom", custom))
+	assert.Equal(t, ErrCryptoEngineAlreadyRegistered, engines.Register("custom", custom))
+	assert.Equal(t, ErrCryptoEngineAlreadyRegistered, engines.Register(Ed25519Sha3EngineName, custom))
+	assert.Equal(t, ErrInvalidCryptoEngine, engines.Register("", custom))
+	assert.Equal(t, ErrInvalidCryptoEngine, engines.Register("other", nil))
+	assert.Equal(t, ErrInvalidCryptoEngine, engines.Register("other", (*Ed25519SeedCryptoEngine)(nil)))
+	assert.Equal(t, ErrInvalidCryptoEngine, engines.Register("other", (*pluggableEngine)(nil)))
+	assert.Equal(t, []string{"custom", Ed25519KeccakEngineName, Ed25519Sha3EngineName, Ed25519Sha512EngineName}, engines.Names())
+
+	engine, err := engines.Get("custom")
+	assert.Nil(t, err)
+	assert.Equal(t, custom, engine)
+}
+
+func TestCryptoEngines_SetDefaultIsUsedByConstructors(t *testing.T) {
+	defer func() {
+		assert.Nil(t, CryptoEngines.SetDefault(Ed25519Sha3EngineName))
+	}()
+	assert.Nil(t, CryptoEngines.SetDefault(Ed25519Sha512EngineName))
+	assert.Equal(t, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Default())
+	assert.Equal(t, Ed25519Sha512EngineName, CryptoEngines.DefaultName())
+
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	signature, err := NewSignerFromKeyPair(kp, nil).Sign([]byte(message))
+	assert.Nil(t, err)
+
+	assert.True(t, CryptoEngines.Ed25519Sha512Engine.CreateDsaSigner(kp).Verify([]byte(message), signature))
+	assert.False(t, CryptoEngines.Ed25519Engine.CreateDsaSigner(kp).Verify([]byte(message), signature))
+}
+
+// seededEngine is a third-party engine that exposes its seed.
+type seededEngine struct {
+	CryptoEngine
+	seed io.Reader
+}
+
+func (ref *seededEngine) Seed() io.Reader {
+	return ref.seed
+}
+
+func TestCryptoEngines_ThirdPartyEngineSeedIsUsed(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x42}, passwordSaltSize+12)
+	engines := newCryptoEngines()
+	assert.Nil(t, engines.Register("seeded", &seededEngine{CryptoEngines.Ed25519Engine, bytes.NewReader(seed)}))
+	engine, err := engines.Get("seeded")
+	assert.Nil(t, err)
+
+	blockCipher := NewPasswordBlockCipher(keystorePassword, engine)
+	blockCipher.Argon2Time, blockCipher.Argon2Memory, blockCipher.Argon2Threads = 1, 64, 1
+	encrypted, err := blockCipher.Encrypt([]byte(message))
+
+	assert.Nil(t, err)
+	assert.Equal(t, seed, encrypted[passwordSaltStart:passwordSaltStart+passwordSaltSize+12])
+	for _, builtIn := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine, &HpkeCryptoEngine{}} {
+		_, ok := builtIn.(SeededEngine)
+		assert.True(t, ok)
+	}
+}
@@ -26,6 +26,14 @@ func TestHashesKeccak_256(t *testing.T) {
 	assert.Equal(t, "241c1d54c18c8422def03aa16b4b243a8ba491374295a1a6965545e6ac1af314", hex.EncodeToString(secretB))
 }
 
+func TestHashesKeccak_512(t *testing.T) {
+	secretB, err := HashesKeccak_512([]byte("abc"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "18587dc2ea106b9a1563e32b3312421ca164c7f1f07bc922a9c83d77cea3a1e5"+
+		"d0c69910739025372dc14ac9642629379540c17e2a65b19d77aa511a9d00bb96", hex.EncodeToString(secretB))
+}
+
 func TestHashesSha3_256(t *testing.T) {
 	const proof = "B778A39A3663719DFC5E48C9D78431B1E45C2AF9DF538782BF199C189DABEAC7"
 
@@ -36,6 +44,14 @@ func TestHashesSha3_256(t *testing.T) {
 	assert.Equal(t, "9b3155b37159da50aa52d5967c509b410f5a36a3b1e31ecb5ac76675d79b4a5e", hex.EncodeToString(secretB))
 }
 
+func TestHashesSha_512(t *testing.T) {
+	secretB, err := HashesSha_512([]byte("abc"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a"+
+		"2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f", hex.EncodeToString(secretB))
+}
+
 func TestHashesSha3_512(t *testing.T) {
 	const proof = "B778A39A3663719DFC5E48C9D78431B1E45C2AF9DF538782BF199C189DABEAC7680ADA57" +
 		"DCEC8EEE91C4E3BF3BFA9AF6FFDE90CD1D249D1C6121D7B759A001B1"
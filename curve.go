@@ -25,5 +25,29 @@ type Curve interface {
 	 *
 	 * @return The group order / 2.
 	 */
-	GetHalfGroupOrder() uint64
+	GetHalfGroupOrder() *big.Int
+}
+
+// Ed25519Curve Class that wraps the elliptic curve Ed25519.
+type Ed25519Curve struct {
+}
+
+// NewEd25519Curve returns the Ed25519 curve
+func NewEd25519Curve() *Ed25519Curve {
+	return &Ed25519Curve{}
+}
+
+// GetName implemented interface Curve method
+func (ref *Ed25519Curve) GetName() string {
+	return "ed25519"
+}
+
+// GetGroupOrder implemented interface Curve method
+func (ref *Ed25519Curve) GetGroupOrder() *big.Int {
+	return (&big.Int{}).Set(Ed25519Group.GROUP_ORDER)
+}
+
+// GetHalfGroupOrder implemented interface Curve method
+func (ref *Ed25519Curve) GetHalfGroupOrder() *big.Int {
+	return (&big.Int{}).Rsh(Ed25519Group.GROUP_ORDER, 1)
 }
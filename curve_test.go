@@ -0,0 +1,48 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEd25519Curve_GetName(t *testing.T) {
+	assert.Equal(t, "ed25519", NewEd25519Curve().GetName())
+}
+
+func TestEd25519Curve_GetGroupOrder(t *testing.T) {
+	// 2^252 + 27742317777372353535851937790883648493
+	expected, _ := (&big.Int{}).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+	assert.Equal(t, expected, NewEd25519Curve().GetGroupOrder())
+}
+
+func TestEd25519Curve_GetHalfGroupOrder(t *testing.T) {
+	expected, _ := (&big.Int{}).SetString("3618502788666131106986593281521497120428558179689953803000975469142727125494", 10)
+
+	assert.Equal(t, expected, NewEd25519Curve().GetHalfGroupOrder())
+}
+
+func TestEd25519Curve_GroupOrderCannotBeModified(t *testing.T) {
+	curve := NewEd25519Curve()
+
+	curve.GetGroupOrder().SetInt64(0)
+
+	assert.Equal(t, Ed25519Group.GROUP_ORDER, curve.GetGroupOrder())
+}
+
+func TestCryptoEngines_GetCurve(t *testing.T) {
+	engines := []CryptoEngine{
+		CryptoEngines.Ed25519Engine,
+		CryptoEngines.Ed25519Sha512Engine,
+		CryptoEngines.Ed25519KeccakEngine,
+	}
+	for _, engine := range engines {
+		assert.Equal(t, "ed25519", engine.GetCurve().GetName())
+	}
+}
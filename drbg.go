@@ -0,0 +1,129 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+const (
+	// hmacDrbgMinSeedSize is the minimal size of the seed: the security strength of HMAC-SHA256 is 256 bits.
+	hmacDrbgMinSeedSize = 32
+	// hmacDrbgMaxRequestSize is the maximum number of bytes produced by one generate call (2^19 bits).
+	hmacDrbgMaxRequestSize = 1 << 16
+	// hmacDrbgReseedInterval is the number of generate calls after which the generator must be reseeded.
+	hmacDrbgReseedInterval = 1 << 48
+)
+
+var (
+	// ErrDrbgSeedTooShort is returned when HmacDrbg is seeded or reseeded with less than 32 bytes.
+	ErrDrbgSeedTooShort = errors.New("seed of deterministic random bit generator must be at least 32 bytes")
+	// ErrDrbgReseedRequired is returned by Read after 2^48 generate requests since HmacDrbg was last seeded.
+	ErrDrbgReseedRequired = errors.New("deterministic random bit generator must be reseeded")
+)
+
+// HmacDrbg is a deterministic random bit generator: HMAC_DRBG with SHA-256 as specified by NIST SP 800-90A.
+// The same seed always produces the same stream of bytes, so it can be passed as the seed of an engine
+// to get reproducible keys, salts and IVs for test fixtures and simulations.
+// It must never be seeded with predictable bytes in production.
+// HmacDrbg is not safe for concurrent use.
+type HmacDrbg struct {
+	k             []byte
+	v             []byte
+	reseedCounter uint64
+}
+
+// NewHmacDrbg instantiates the generator from seed and an optional personalization string.
+func NewHmacDrbg(seed []byte, personalization []byte) (*HmacDrbg, error) {
+	if len(seed) < hmacDrbgMinSeedSize {
+		return nil, ErrDrbgSeedTooShort
+	}
+
+	ref := &HmacDrbg{
+		make([]byte, sha256.Size),
+		make([]byte, sha256.Size),
+		1,
+	}
+	for i := range ref.v {
+		ref.v[i] = 0x01
+	}
+	ref.update(seed, personalization)
+
+	return ref, nil
+}
+
+// Reseed mixes new entropy into the state of the generator.
+func (ref *HmacDrbg) Reseed(entropy []byte, additional []byte) error {
+	if len(entropy) < hmacDrbgMinSeedSize {
+		return ErrDrbgSeedTooShort
+	}
+
+	ref.update(entropy, additional)
+	ref.reseedCounter = 1
+
+	return nil
+}
+
+// Read implements io.Reader and fills p with deterministic pseudo random bytes.
+func (ref *HmacDrbg) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		size := len(p) - n
+		if size > hmacDrbgMaxRequestSize {
+			size = hmacDrbgMaxRequestSize
+		}
+
+		if err := ref.generate(p[n : n+size]); err != nil {
+			return n, err
+		}
+		n += size
+	}
+
+	return n, nil
+}
+
+func (ref *HmacDrbg) generate(out []byte) error {
+	if ref.reseedCounter > hmacDrbgReseedInterval {
+		return ErrDrbgReseedRequired
+	}
+
+	for i := 0; i < len(out); {
+		ref.v = ref.hmac(ref.k, ref.v)
+		i += copy(out[i:], ref.v)
+	}
+	ref.update()
+	ref.reseedCounter++
+
+	return nil
+}
+
+// update is the HMAC_DRBG_Update function of SP 800-90A.
+func (ref *HmacDrbg) update(provided ...[]byte) {
+	empty := true
+	for _, b := range provided {
+		empty = empty && len(b) == 0
+	}
+
+	ref.k = ref.hmac(ref.k, append(append([]byte{}, ref.v...), 0x00), provided...)
+	ref.v = ref.hmac(ref.k, ref.v)
+	if empty {
+		return
+	}
+
+	ref.k = ref.hmac(ref.k, append(append([]byte{}, ref.v...), 0x01), provided...)
+	ref.v = ref.hmac(ref.k, ref.v)
+}
+
+func (ref *HmacDrbg) hmac(key []byte, data []byte, more ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	for _, b := range more {
+		mac.Write(b)
+	}
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var drbgTestSeed = []byte("0123456789abcdef0123456789abcdef")
+
+func TestHmacDrbg_NISTVector(t *testing.T) {
+	// CAVP HMAC_DRBG.rsp, [SHA-256] [PredictionResistance = False], COUNT = 0
+	seed, err := hex.DecodeString("ca851911349384bffe89de1cbdc46e6831e44d34a4fb935ee285dd14b71a7488" +
+		"659ba96c601dc69fc902940805ec0ca8")
+	assert.Nil(t, err)
+	drbg, err := NewHmacDrbg(seed, nil)
+	assert.Nil(t, err)
+
+	out := make([]byte, 128)
+	_, err = io.ReadFull(drbg, out)
+	assert.Nil(t, err)
+	_, err = io.ReadFull(drbg, out)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "e528e9abf2dece54d47c7e75e5fe302149f817ea9fb4bee6f4199697d04d5b89"+
+		"d54fbb978a15b5c443c9ec21036d2460b6f73ebad0dc2aba6e624abf07745bc1"+
+		"07694bb7547bb0995f70de25d6b29e2d3011bb19d27676c07162c8b5ccde0668"+
+		"961df86803482cb37ed6d5c0bb8d50cf1f50d476aa0458bdaba806f48be9dcb8", hex.EncodeToString(out))
+}
+
+func TestHmacDrbg_RejectsShortSeed(t *testing.T) {
+	_, err := NewHmacDrbg([]byte("short"), nil)
+
+	assert.Equal(t, ErrDrbgSeedTooShort, err)
+}
+
+func TestHmacDrbg_PersonalizationChangesOutput(t *testing.T) {
+	drbg1, err := NewHmacDrbg(drbgTestSeed, nil)
+	assert.Nil(t, err)
+	drbg2, err := NewHmacDrbg(drbgTestSeed, []byte("simulation #2"))
+	assert.Nil(t, err)
+
+	out1, out2 := make([]byte, 32), make([]byte, 32)
+	_, err = io.ReadFull(drbg1, out1)
+	assert.Nil(t, err)
+	_, err = io.ReadFull(drbg2, out2)
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, out1, out2)
+}
+
+func TestNewEd25519SeedCryptoEngine_IsReproducible(t *testing.T) {
+	newEngine := func() *Ed25519SeedCryptoEngine {
+		drbg, err := NewHmacDrbg(drbgTestSeed, nil)
+		assert.Nil(t, err)
+		return NewEd25519SeedCryptoEngine(drbg)
+	}
+	engine1, engine2 := newEngine(), newEngine()
+
+	sender1, err := NewKeyPairByEngine(engine1)
+	assert.Nil(t, err)
+	recipient1, err := NewKeyPairByEngine(engine1)
+	assert.Nil(t, err)
+	sender2, err := NewKeyPairByEngine(engine2)
+	assert.Nil(t, err)
+	recipient2, err := NewKeyPairByEngine(engine2)
+	assert.Nil(t, err)
+	assert.Equal(t, sender1.PrivateKey.Raw, sender2.PrivateKey.Raw)
+	assert.Equal(t, recipient1.PrivateKey.Raw, recipient2.PrivateKey.Raw)
+	assert.NotEqual(t, sender1.PrivateKey.Raw, recipient1.PrivateKey.Raw)
+
+	encrypted1, err := engine1.CreateBlockCipher(sender1, recipient1).Encrypt([]byte(message))
+	assert.Nil(t, err)
+	encrypted2, err := engine2.CreateBlockCipher(sender2, recipient2).Encrypt([]byte(message))
+	assert.Nil(t, err)
+	assert.Equal(t, encrypted1, encrypted2)
+}
@@ -10,8 +10,28 @@ type DsaSigner interface {
 	Sign(mess []byte) (*Signature, error)
 	// Verifies that the signature is valid.
 	Verify(mess []byte, signature *Signature) bool
+	// Verifies that the signature is valid and returns the reason if it is not.
+	VerifyWithError(mess []byte, signature *Signature) error
 	// Determines if the signature is canonical.
 	IsCanonicalSignature(signature *Signature) bool
 	// Makes ref signature canonical.
 	MakeSignatureCanonical(signature *Signature) (*Signature, error)
 }
+
+// VerificationPolicy selects the rules a signature is accepted by.
+// Every node of a network must use the same policy to accept exactly the same signatures.
+type VerificationPolicy int
+
+const (
+	// VerifyLegacy is the original rule of this library:
+	// the encoding of S * B - h * A must be equal to R (no cofactor), small order points are accepted
+	// and the zero public key is rejected.
+	VerifyLegacy VerificationPolicy = iota
+	// VerifyStrict follows RFC 8032 strictly: it rejects S >= L, non-canonical encodings of A and R
+	// and small order A and R, then checks the cofactored equation 8 * S * B = 8 * R + 8 * h * A.
+	VerifyStrict
+	// VerifyZip215 follows the ZIP-215 consensus rules: it rejects S >= L, accepts non-canonical encodings
+	// and small order points and checks the cofactored equation 8 * S * B = 8 * R + 8 * h * A.
+	// Ed25519BatchVerifier gives exactly the same answers under this policy.
+	VerifyZip215
+)
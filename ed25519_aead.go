@@ -0,0 +1,366 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/sha3"
+)
+
+// CipherMode selects the format Ed25519BlockCipher encrypts to.
+type CipherMode int
+
+const (
+	// CipherModeLegacy is the original format: salt | IV | AES-256-CBC ciphertext with PKCS#7 padding.
+	// It is not authenticated. Decrypt accepts both formats in this mode.
+	CipherModeLegacy CipherMode = iota
+	// CipherModeAead is the authenticated format:
+	// "XPX" | version | suite | salt | nonce | AES-256-GCM ciphertext and tag.
+	// Decrypt rejects the legacy format in this mode.
+	CipherModeAead
+)
+
+// CipherSuite selects the AEAD of the AEAD, stream, envelope and sealed box formats, it is recorded in their header.
+type CipherSuite uint8
+
+const (
+	// CipherSuiteAes256Gcm is AES-256-GCM.
+	CipherSuiteAes256Gcm CipherSuite = aeadSuiteAes256Gcm
+	// CipherSuiteChaCha20Poly1305 is ChaCha20-Poly1305 (RFC 8439), for platforms without AES instructions.
+	CipherSuiteChaCha20Poly1305 CipherSuite = 2
+	// CipherSuiteXChaCha20Poly1305 is XChaCha20-Poly1305 with 24 bytes nonces.
+	CipherSuiteXChaCha20Poly1305 CipherSuite = 3
+)
+
+// KeyDerivation selects how the AEAD and stream formats derive their key from the ECDH shared secret,
+// it is recorded in their header. The legacy format only supports KeyDerivationLegacy.
+type KeyDerivation uint8
+
+const (
+	// KeyDerivationLegacy keeps the derivation every format had before: format version 1 of the AEAD and stream formats
+	// uses HKDF-SHA256 with a fixed info, DeriveSharedKey uses GetSharedKey.
+	KeyDerivationLegacy KeyDerivation = iota
+	// KeyDerivationHkdfSha256 is HKDF-SHA256 with the info bound to the public keys of the sender and the recipient.
+	KeyDerivationHkdfSha256
+	// KeyDerivationHkdfSha3_256 is HKDF-SHA3-256 with the info bound to the public keys of the sender and the recipient.
+	KeyDerivationHkdfSha3_256
+)
+
+const (
+	// aeadFormatVersion is the version of the AEAD format.
+	aeadFormatVersion = 1
+	// aeadFormatVersionKdf is the version of the AEAD format with a key derivation byte after the suite.
+	aeadFormatVersionKdf = 2
+	// aeadSuiteAes256Gcm is AES-256-GCM with the key derived by HKDF-SHA256 from the ECDH shared secret.
+	aeadSuiteAes256Gcm = 1
+	// aeadSaltSize is the size of the HKDF salt.
+	aeadSaltSize = 32
+	// legacyHeaderSize is the size of salt | IV of the legacy format.
+	legacyHeaderSize = 48
+)
+
+var (
+	// aeadMagic starts every AEAD ciphertext.
+	aeadMagic = []byte("XPX")
+	// aeadHeaderSize is the size of "XPX" | version | suite.
+	aeadHeaderSize = len(aeadMagic) + 2
+	// aeadKeyInfo is the HKDF info of the AES-256-GCM key.
+	aeadKeyInfo = []byte("xpx-crypto aes-256-gcm")
+	// sharedKeyInfo starts the HKDF info of DeriveSharedKey.
+	sharedKeyInfo = []byte("xpx-crypto shared key")
+)
+
+var (
+	// ErrDecryptionFailed is returned when a ciphertext cannot be decrypted,
+	// it does not tell which check failed.
+	ErrDecryptionFailed = errors.New("cannot decrypt message")
+	// ErrLegacyCiphertext is returned when a legacy ciphertext is decrypted where authentication is required.
+	ErrLegacyCiphertext = errors.New("legacy ciphertext is not authenticated")
+	// ErrUnsupportedCipherFormat is returned when an AEAD ciphertext has an unknown version or suite.
+	ErrUnsupportedCipherFormat = errors.New("unsupported ciphertext version or suite")
+	// ErrInvalidSharedSecret is returned when the ECDH shared secret is the neutral element,
+	// which happens when the public key has a small order.
+	ErrInvalidSharedSecret = errors.New("shared secret has small order")
+	// ErrInvalidSaltSize is returned when the salt of GetSharedKey is shorter than a public key.
+	ErrInvalidSaltSize = errors.New("salt is shorter than the public key")
+	// ErrUnknownKeyDerivation is returned when KeyDerivation is not a known derivation.
+	ErrUnknownKeyDerivation = errors.New("unknown key derivation")
+	// ErrUnknownCipherSuite is returned when Suite is not a known cipher suite.
+	ErrUnknownCipherSuite = errors.New("unknown cipher suite")
+	// ErrLegacyCipherSuite is returned when Encrypt is called in CipherModeLegacy
+	// with a Suite other than CipherSuiteAes256Gcm, the legacy format only encrypts with AES-256-CBC.
+	ErrLegacyCipherSuite = errors.New("legacy format does not support the cipher suite, use CipherModeAead")
+	// ErrLegacyKeyDerivation is returned when Encrypt is called in CipherModeLegacy
+	// with a KeyDerivation other than KeyDerivationLegacy, the legacy format always uses GetSharedKey.
+	ErrLegacyKeyDerivation = errors.New("legacy format does not support the key derivation, use CipherModeAead")
+)
+
+// EncryptWithAssociatedData encrypts input with the AEAD of Suite in the AEAD format,
+// associatedData is authenticated but not encrypted. It does not depend on Mode.
+func (ref *Ed25519BlockCipher) EncryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	salt := make([]byte, aeadSaltSize)
+	_, err := io.ReadFull(ref.seed, salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := ref.newAeadWithKeyDerivation(ref.senderKeyPair.PrivateKey, ref.recipientKeyPair.PublicKey, salt, ref.KeyDerivation, ref.Suite)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(ref.seed, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, aeadHeaderSize+1+len(salt)+len(nonce)+len(input)+aead.Overhead())
+	header = append(header, aeadMagic...)
+	if ref.KeyDerivation == KeyDerivationLegacy {
+		header = append(header, aeadFormatVersion, byte(ref.Suite))
+	} else {
+		header = append(header, aeadFormatVersionKdf, byte(ref.Suite), byte(ref.KeyDerivation))
+	}
+	header = append(header, salt...)
+	header = append(header, nonce...)
+
+	return aead.Seal(header, nonce, input, aeadAssociatedData(header, associatedData)), nil
+}
+
+// DecryptWithAssociatedData decrypts an AEAD ciphertext created with the same associatedData.
+// The cipher suite and the key derivation are read from the ciphertext, Suite and KeyDerivation are not used.
+// The legacy format is rejected with ErrLegacyCiphertext.
+func (ref *Ed25519BlockCipher) DecryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	if len(input) < aeadHeaderSize || !bytes.Equal(input[:len(aeadMagic)], aeadMagic) {
+		return nil, ErrLegacyCiphertext
+	}
+	suite := CipherSuite(input[len(aeadMagic)+1])
+	if !suite.isKnown() {
+		return nil, ErrUnsupportedCipherFormat
+	}
+	prefixSize := aeadHeaderSize
+	keyDerivation := KeyDerivationLegacy
+	switch input[len(aeadMagic)] {
+	case aeadFormatVersion:
+	case aeadFormatVersionKdf:
+		if len(input) <= aeadHeaderSize {
+			return nil, ErrDecryptionFailed
+		}
+		keyDerivation = KeyDerivation(input[aeadHeaderSize])
+		if keyDerivation == KeyDerivationLegacy || keyDerivation > KeyDerivationHkdfSha3_256 {
+			return nil, ErrUnsupportedCipherFormat
+		}
+		prefixSize++
+	default:
+		return nil, ErrUnsupportedCipherFormat
+	}
+	if len(input) < prefixSize+aeadSaltSize {
+		return nil, ErrDecryptionFailed
+	}
+
+	salt := input[prefixSize : prefixSize+aeadSaltSize]
+	aead, err := ref.newAeadWithKeyDerivation(ref.recipientKeyPair.PrivateKey, ref.senderKeyPair.PublicKey, salt, keyDerivation, suite)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := prefixSize + aeadSaltSize + aead.NonceSize()
+	if len(input) < headerSize+aead.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+	header := input[:headerSize]
+	plaintext, err := aead.Open(nil, input[headerSize-aead.NonceSize():headerSize], input[headerSize:], aeadAssociatedData(header, associatedData))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// DeriveSharedKey derives the 32 bytes shared key of privateKey and publicKey with KeyDerivation.
+// The HKDF derivations bind the info to the public keys of the sender and the recipient of the block cipher,
+// so both sides must use a block cipher with the same sender and recipient.
+// KeyDerivationLegacy is GetSharedKey.
+func (ref *Ed25519BlockCipher) DeriveSharedKey(privateKey *PrivateKey, publicKey *PublicKey, salt []byte) ([]byte, error) {
+
+	return ref.deriveSharedKey(privateKey, publicKey, salt, ref.KeyDerivation)
+}
+
+func (ref *Ed25519BlockCipher) deriveSharedKey(privateKey *PrivateKey, publicKey *PublicKey, salt []byte, keyDerivation KeyDerivation) ([]byte, error) {
+
+	if keyDerivation == KeyDerivationLegacy {
+		return ref.GetSharedKey(privateKey, publicKey, salt)
+	}
+	newHash, err := keyDerivation.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.hkdfSharedKey(privateKey, publicKey, newHash, salt, ref.keyDerivationInfo(keyDerivation))
+}
+
+// newAeadWithKeyDerivation creates the cipher of the AEAD format,
+// KeyDerivationLegacy is the derivation of format version 1.
+func (ref *Ed25519BlockCipher) newAeadWithKeyDerivation(privateKey *PrivateKey, publicKey *PublicKey, salt []byte, keyDerivation KeyDerivation, suite CipherSuite) (cipher.AEAD, error) {
+
+	if keyDerivation == KeyDerivationLegacy {
+		return ref.newAead(privateKey, publicKey, salt, aeadKeyInfo, suite)
+	}
+
+	key, err := ref.deriveSharedKey(privateKey, publicKey, salt, keyDerivation)
+	if err != nil {
+		return nil, err
+	}
+
+	return suite.newAead(key)
+}
+
+// newAead derives the key of suite with HKDF-SHA256 from the ECDH shared secret of privateKey and publicKey,
+// info separates the keys of different formats.
+func (ref *Ed25519BlockCipher) newAead(privateKey *PrivateKey, publicKey *PublicKey, salt []byte, info []byte, suite CipherSuite) (cipher.AEAD, error) {
+
+	key, err := ref.hkdfSharedKey(privateKey, publicKey, sha256.New, salt, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return suite.newAead(key)
+}
+
+// hkdfSharedKey derives a 32 bytes key with HKDF from the ECDH shared secret of privateKey and publicKey.
+func (ref *Ed25519BlockCipher) hkdfSharedKey(privateKey *PrivateKey, publicKey *PublicKey, newHash func() hash.Hash, salt []byte, info []byte) ([]byte, error) {
+
+	secret, err := ref.sharedSecret(privateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if isNeutralEncoding(secret) {
+		return nil, ErrInvalidSharedSecret
+	}
+
+	key := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(newHash, secret, salt, info), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// keyDerivationInfo is "xpx-crypto shared key" | key derivation | sender public key | recipient public key.
+func (ref *Ed25519BlockCipher) keyDerivationInfo(keyDerivation KeyDerivation) []byte {
+
+	info := make([]byte, 0, len(sharedKeyInfo)+1+2*ref.keyLength)
+	info = append(info, sharedKeyInfo...)
+	info = append(info, byte(keyDerivation))
+	info = append(info, ref.senderKeyPair.PublicKey.Raw...)
+	return append(info, ref.recipientKeyPair.PublicKey.Raw...)
+}
+
+// aeadAssociatedData authenticates the header of the ciphertext together with associatedData.
+func aeadAssociatedData(header []byte, associatedData []byte) []byte {
+
+	data := make([]byte, 0, len(header)+len(associatedData))
+	data = append(data, header...)
+	return append(data, associatedData...)
+}
+
+// isAeadCiphertext reports whether input starts with the AEAD header:
+// "XPX", a known version and a known suite.
+func isAeadCiphertext(input []byte) bool {
+
+	if len(input) < aeadHeaderSize || !bytes.Equal(input[:len(aeadMagic)], aeadMagic) {
+		return false
+	}
+	version := input[len(aeadMagic)]
+
+	return (version == aeadFormatVersion || version == aeadFormatVersionKdf) && CipherSuite(input[len(aeadMagic)+1]).isKnown()
+}
+
+// isLegacyCiphertextSize reports whether input has the size of a legacy ciphertext.
+func isLegacyCiphertextSize(input []byte) bool {
+
+	return len(input) >= legacyHeaderSize+aes.BlockSize && (len(input)-legacyHeaderSize)%aes.BlockSize == 0
+}
+
+// isNeutralEncoding reports whether b is the encoding of the neutral element (0, 1).
+func isNeutralEncoding(b []byte) bool {
+
+	neutral := make([]byte, len(b))
+	neutral[0] = 1
+	return isEqualConstantTime(b, neutral)
+}
+
+// newHash returns the hash of the HKDF of the key derivation.
+func (ref KeyDerivation) newHash() (func() hash.Hash, error) {
+
+	switch ref {
+	case KeyDerivationHkdfSha256:
+		return sha256.New, nil
+	case KeyDerivationHkdfSha3_256:
+		return sha3.New256, nil
+	}
+
+	return nil, ErrUnknownKeyDerivation
+}
+
+// isKnown reports whether the suite is one of the cipher suites.
+func (ref CipherSuite) isKnown() bool {
+
+	return ref >= CipherSuiteAes256Gcm && ref <= CipherSuiteXChaCha20Poly1305
+}
+
+// nonceSize returns the nonce size of the AEAD of the suite.
+func (ref CipherSuite) nonceSize() int {
+
+	if ref == CipherSuiteXChaCha20Poly1305 {
+		return chacha20poly1305.NonceSizeX
+	}
+
+	return chacha20poly1305.NonceSize
+}
+
+// newAead creates the AEAD of the suite with a 32 bytes key.
+func (ref CipherSuite) newAead(key []byte) (cipher.AEAD, error) {
+
+	switch ref {
+	case CipherSuiteAes256Gcm:
+		return newAes256Gcm(key)
+	case CipherSuiteChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case CipherSuiteXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+
+	return nil, ErrUnknownCipherSuite
+}
+
+// keyInfo returns the HKDF info prefix | suite, so every suite of a format has its own key.
+func (ref CipherSuite) keyInfo(prefix []byte) []byte {
+
+	info := make([]byte, 0, len(prefix)+1)
+	info = append(info, prefix...)
+	return append(info, byte(ref))
+}
+
+// newAes256Gcm creates the AES-256-GCM cipher of key.
+func newAes256Gcm(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,344 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAeadBlockCiphers(t *testing.T, engine CryptoEngine) (*Ed25519BlockCipher, *Ed25519BlockCipher) {
+	sender, err := NewKeyPairByEngine(engine)
+	assert.Nil(t, err)
+	recipient, err := NewKeyPairByEngine(engine)
+	assert.Nil(t, err)
+
+	encrypter := engine.CreateBlockCipher(sender, recipient).(*Ed25519BlockCipher)
+	decrypter := engine.CreateBlockCipher(sender, recipient).(*Ed25519BlockCipher)
+	encrypter.Mode, decrypter.Mode = CipherModeAead, CipherModeAead
+
+	return encrypter, decrypter
+}
+
+func TestEd25519BlockCipher_AeadRoundTrip(t *testing.T) {
+	var _ AeadBlockCipher = (*Ed25519BlockCipher)(nil)
+
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		encrypter, decrypter := newAeadBlockCiphers(t, engine)
+		for _, size := range []int{0, 1, 16, 1000} {
+			input := make([]byte, size)
+			_, err := rand.Read(input)
+			assert.Nil(t, err)
+
+			encrypted, err := encrypter.Encrypt(input)
+			assert.Nil(t, err)
+			assert.Equal(t, []byte{'X', 'P', 'X', aeadFormatVersion, aeadSuiteAes256Gcm}, encrypted[:aeadHeaderSize])
+			assert.Len(t, encrypted, aeadHeaderSize+aeadSaltSize+12+size+16)
+
+			decrypted, err := decrypter.Decrypt(encrypted)
+			assert.Nil(t, err)
+			assert.Equal(t, input, append([]byte{}, decrypted...))
+		}
+	}
+}
+
+func TestEd25519BlockCipher_AeadAuthenticatesAssociatedData(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+
+	encrypted, err := encrypter.EncryptWithAssociatedData([]byte(message), []byte("transfer #1"))
+	assert.Nil(t, err)
+
+	decrypted, err := decrypter.DecryptWithAssociatedData(encrypted, []byte("transfer #1"))
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(decrypted))
+
+	_, err = decrypter.DecryptWithAssociatedData(encrypted, []byte("transfer #2"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = decrypter.Decrypt(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_AeadDetectsTampering(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	encrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+
+	for i := aeadHeaderSize; i < len(encrypted); i++ {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+
+		_, err := decrypter.Decrypt(tampered)
+		assert.Equalf(t, ErrDecryptionFailed, err, "byte %d", i)
+	}
+
+	for _, i := range []int{len(aeadMagic), len(aeadMagic) + 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] = 0xff
+
+		_, err := decrypter.Decrypt(tampered)
+		assert.Equal(t, ErrUnsupportedCipherFormat, err)
+	}
+
+	_, err = decrypter.Decrypt(encrypted[:aeadHeaderSize+aeadSaltSize+12+15])
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = decrypter.Decrypt(encrypted[:aeadHeaderSize+10])
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_DecryptDetectsFormat(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	aeadEncrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+	encrypter.Mode = CipherModeLegacy
+	legacyEncrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+
+	// the AEAD mode accepts only authenticated ciphertexts
+	_, err = decrypter.Decrypt(legacyEncrypted)
+	assert.Equal(t, ErrLegacyCiphertext, err)
+	_, err = decrypter.DecryptWithAssociatedData(legacyEncrypted, nil)
+	assert.Equal(t, ErrLegacyCiphertext, err)
+
+	// the legacy mode accepts both
+	decrypter.Mode = CipherModeLegacy
+	for _, encrypted := range [][]byte{aeadEncrypted, legacyEncrypted} {
+		decrypted, err := decrypter.Decrypt(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(decrypted))
+	}
+}
+
+func TestEd25519BlockCipher_LegacySaltStartingWithAeadHeader(t *testing.T) {
+	sender, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+
+	// a known header, an unknown version and an unknown suite
+	for _, header := range [][]byte{
+		{'X', 'P', 'X', aeadFormatVersion, aeadSuiteAes256Gcm},
+		{'X', 'P', 'X', 0xff, aeadSuiteAes256Gcm},
+		{'X', 'P', 'X', aeadFormatVersion, 0xff},
+	} {
+		seed := make([]byte, 48)
+		copy(seed, header)
+		encrypter := NewEd25519BlockCipher(sender, recipient, bytes.NewReader(seed))
+
+		encrypted, err := encrypter.Encrypt([]byte(message))
+		assert.Nil(t, err)
+		assert.Equal(t, isAeadCiphertext(header), isAeadCiphertext(encrypted))
+		decrypted, err := NewEd25519BlockCipher(sender, recipient, nil).Decrypt(encrypted)
+
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(decrypted))
+	}
+}
+
+func TestEd25519BlockCipher_LegacyPaddingFailuresAreIndistinguishable(t *testing.T) {
+	sender, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	blockCipher := NewEd25519BlockCipher(sender, recipient, nil)
+	encrypted, err := blockCipher.Encrypt([]byte("two blocks of message"))
+	assert.Nil(t, err)
+
+	// the last byte of the previous block changes the last padding byte
+	for _, flip := range []byte{0x01, 0x10, 0x20, 0xff} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(tampered)-17] ^= flip
+
+		_, err = blockCipher.Decrypt(tampered)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+
+	_, err = blockCipher.Decrypt(encrypted[:len(encrypted)-1])
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_AeadRejectsSmallOrderPublicKey(t *testing.T) {
+	sender, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	for _, encoded := range []string{
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	} {
+		raw, err := hex.DecodeString(encoded)
+		assert.Nil(t, err)
+		blockCipher := NewEd25519BlockCipher(sender, &KeyPair{nil, NewPublicKey(raw)}, nil)
+
+		_, err = blockCipher.EncryptWithAssociatedData([]byte(message), nil)
+		assert.Equal(t, ErrInvalidSharedSecret, err)
+	}
+}
+
+func TestEd25519BlockCipher_AeadKeyDerivations(t *testing.T) {
+	for _, keyDerivation := range []KeyDerivation{KeyDerivationHkdfSha256, KeyDerivationHkdfSha3_256} {
+		encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+		encrypter.KeyDerivation = keyDerivation
+
+		encrypted, err := encrypter.EncryptWithAssociatedData([]byte(message), []byte("data"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{'X', 'P', 'X', aeadFormatVersionKdf, aeadSuiteAes256Gcm, byte(keyDerivation)}, encrypted[:aeadHeaderSize+1])
+		assert.Len(t, encrypted, aeadHeaderSize+1+aeadSaltSize+12+len(message)+16)
+
+		// the derivation is read from the ciphertext
+		decrypted, err := decrypter.DecryptWithAssociatedData(encrypted, []byte("data"))
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(decrypted))
+
+		tampered := append([]byte{}, encrypted...)
+		tampered[aeadHeaderSize] ^= byte(KeyDerivationHkdfSha256 ^ KeyDerivationHkdfSha3_256)
+		_, err = decrypter.DecryptWithAssociatedData(tampered, []byte("data"))
+		assert.Equal(t, ErrDecryptionFailed, err)
+		for _, unknown := range []byte{byte(KeyDerivationLegacy), 3, 0xff} {
+			tampered[aeadHeaderSize] = unknown
+			_, err = decrypter.DecryptWithAssociatedData(tampered, []byte("data"))
+			assert.Equal(t, ErrUnsupportedCipherFormat, err)
+		}
+		_, err = decrypter.DecryptWithAssociatedData(encrypted[:aeadHeaderSize], nil)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+}
+
+func TestEd25519BlockCipher_DeriveSharedKey(t *testing.T) {
+	sender, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	encrypter := NewEd25519BlockCipher(sender, recipient, nil)
+	decrypter := NewEd25519BlockCipher(sender, recipient, nil)
+	salt := make([]byte, 32)
+	_, err = rand.Read(salt)
+	assert.Nil(t, err)
+
+	legacy, err := encrypter.DeriveSharedKey(sender.PrivateKey, recipient.PublicKey, salt)
+	assert.Nil(t, err)
+	expected, err := encrypter.GetSharedKey(sender.PrivateKey, recipient.PublicKey, salt)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, legacy)
+
+	keys := map[string]bool{hex.EncodeToString(legacy): true}
+	for _, keyDerivation := range []KeyDerivation{KeyDerivationHkdfSha256, KeyDerivationHkdfSha3_256} {
+		encrypter.KeyDerivation, decrypter.KeyDerivation = keyDerivation, keyDerivation
+
+		key, err := encrypter.DeriveSharedKey(sender.PrivateKey, recipient.PublicKey, salt)
+		assert.Nil(t, err)
+		assert.Len(t, key, 32)
+		other, err := decrypter.DeriveSharedKey(recipient.PrivateKey, sender.PublicKey, salt)
+		assert.Nil(t, err)
+		assert.Equal(t, key, other)
+
+		// the info binds the direction, the reverse channel has another key
+		reversed, err := NewEd25519BlockCipher(recipient, sender, nil).deriveSharedKey(recipient.PrivateKey, sender.PublicKey, salt, keyDerivation)
+		assert.Nil(t, err)
+		assert.NotEqual(t, key, reversed)
+
+		keys[hex.EncodeToString(key)] = true
+	}
+	assert.Len(t, keys, 3)
+
+	encrypter.KeyDerivation = KeyDerivation(9)
+	_, err = encrypter.DeriveSharedKey(sender.PrivateKey, recipient.PublicKey, salt)
+	assert.Equal(t, ErrUnknownKeyDerivation, err)
+	_, err = encrypter.EncryptWithAssociatedData([]byte(message), nil)
+	assert.Equal(t, ErrUnknownKeyDerivation, err)
+}
+
+func TestEd25519BlockCipher_GetSharedKeyChecksSaltSize(t *testing.T) {
+	sender, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+
+	_, err = NewEd25519BlockCipher(sender, recipient, nil).GetSharedKey(sender.PrivateKey, recipient.PublicKey, make([]byte, 31))
+	assert.Equal(t, ErrInvalidSaltSize, err)
+}
+
+func TestEd25519BlockCipher_CipherSuites(t *testing.T) {
+	for _, suite := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+		for _, keyDerivation := range []KeyDerivation{KeyDerivationLegacy, KeyDerivationHkdfSha3_256} {
+			encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+			encrypter.Suite, encrypter.KeyDerivation = suite, keyDerivation
+
+			encrypted, err := encrypter.Encrypt([]byte(message))
+			assert.Nil(t, err)
+			assert.Equal(t, byte(suite), encrypted[len(aeadMagic)+1])
+			prefixSize := aeadHeaderSize
+			if keyDerivation != KeyDerivationLegacy {
+				prefixSize++
+			}
+			assert.Len(t, encrypted, prefixSize+aeadSaltSize+suite.nonceSize()+len(message)+16)
+
+			// the suite is read from the ciphertext
+			decrypted, err := decrypter.Decrypt(encrypted)
+			assert.Nil(t, err)
+			assert.Equal(t, message, string(decrypted))
+
+			for _, other := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+				if other == suite {
+					continue
+				}
+				tampered := append([]byte{}, encrypted...)
+				tampered[len(aeadMagic)+1] = byte(other)
+				_, err = decrypter.Decrypt(tampered)
+				assert.Equal(t, ErrDecryptionFailed, err)
+			}
+		}
+	}
+
+	encrypter, _ := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	encrypter.Suite = CipherSuite(0)
+	_, err := encrypter.Encrypt([]byte(message))
+	assert.Equal(t, ErrUnknownCipherSuite, err)
+	_, err = encrypter.NewStreamEncrypter(ioutil.Discard)
+	assert.Equal(t, ErrUnknownCipherSuite, err)
+}
+
+func TestEd25519BlockCipher_LegacyModeRejectsOtherSuites(t *testing.T) {
+	for _, suite := range []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305, CipherSuite(0)} {
+		encrypter, _ := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+		encrypter.Mode, encrypter.Suite = CipherModeLegacy, suite
+
+		encrypted, err := encrypter.Encrypt([]byte(message))
+
+		assert.Nil(t, encrypted)
+		assert.Equalf(t, ErrLegacyCipherSuite, err, "suite %d", suite)
+	}
+}
+
+func TestEd25519BlockCipher_SuitesHaveSeparateKeys(t *testing.T) {
+	encrypter, _ := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	salt := make([]byte, aeadSaltSize)
+
+	for _, info := range [][]byte{streamKeyInfo, sealedBoxKeyInfo} {
+		keys := map[string]CipherSuite{}
+		for _, suite := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+			key, err := encrypter.hkdfSharedKey(encrypter.senderKeyPair.PrivateKey, encrypter.recipientKeyPair.PublicKey, sha256.New, salt, suite.keyInfo(info))
+			assert.Nil(t, err)
+			keys[hex.EncodeToString(key)] = suite
+		}
+
+		assert.Lenf(t, keys, 3, "%s", info)
+	}
+}
+
+func TestEd25519BlockCipher_LegacyModeRejectsOtherKeyDerivations(t *testing.T) {
+	for _, keyDerivation := range []KeyDerivation{KeyDerivationHkdfSha256, KeyDerivationHkdfSha3_256} {
+		encrypter, _ := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+		encrypter.Mode, encrypter.KeyDerivation = CipherModeLegacy, keyDerivation
+
+		encrypted, err := encrypter.Encrypt([]byte(message))
+
+		assert.Nil(t, encrypted)
+		assert.Equalf(t, ErrLegacyKeyDerivation, err, "key derivation %d", keyDerivation)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// batchEntryCoefficientSize is the size in bytes of the random coefficient every entry of a batch is multiplied with.
+const batchEntryCoefficientSize = 16
+
+type batchEntry struct {
+	publicKey *PublicKey
+	mess      []byte
+	signature *Signature
+}
+
+// Ed25519BatchVerifier verifies many Ed25519 signatures together.
+// All entries are checked by a single random linear combination
+// z_0 * (S_0 * B - R_0 - h_0 * A_0) + z_1 * (S_1 * B - R_1 - h_1 * A_1) + ... = 0
+// that is computed with one multi scalar multiplication, which is much cheaper
+// than calling Ed25519DsaSigner.Verify for every entry.
+// The combination is multiplied by the cofactor, so under VerifyStrict and VerifyZip215
+// the batch accepts exactly the signatures Ed25519DsaSigner.Verify accepts.
+// VerifyLegacy checks every signature without the cofactor, which a batch equation cannot reproduce,
+// so under VerifyLegacy the entries are verified one by one and the batch is not faster than Verify.
+//
+// The policy is a consensus rule: nodes that verify the same transactions must use the same policy.
+// VerifyZip215 accepts every signature VerifyLegacy accepts and also some signatures it rejects,
+// so a network can only move from VerifyLegacy to VerifyZip215 with all nodes at once.
+type Ed25519BatchVerifier struct {
+	// Policy selects the rules entries are accepted by.
+	Policy  VerificationPolicy
+	entries []*batchEntry
+	schema  *ed25519Schema
+}
+
+// NewEd25519BatchVerifier creates an empty batch verifier that accepts entries by policy.
+// Only VerifyStrict and VerifyZip215 are verified by the batch equation.
+// The random coefficients are always read from crypto/rand:
+// an attacker who can predict them can build an invalid batch that passes.
+func NewEd25519BatchVerifier(policy VerificationPolicy) *Ed25519BatchVerifier {
+
+	return &Ed25519BatchVerifier{policy, nil, ed25519Sha3Schema}
+}
+
+// Add appends a (public key, message, signature) triple to the batch.
+func (ref *Ed25519BatchVerifier) Add(publicKey *PublicKey, mess []byte, signature *Signature) {
+
+	ref.entries = append(ref.entries, &batchEntry{publicKey, mess, signature})
+}
+
+// Len returns the number of entries in the batch.
+func (ref *Ed25519BatchVerifier) Len() int {
+
+	return len(ref.entries)
+}
+
+// Verify checks all entries of the batch.
+// It returns true if every signature is valid.
+// Otherwise it verifies the entries one by one and the returned slice tells which of them are valid.
+func (ref *Ed25519BatchVerifier) Verify() (bool, []bool) {
+
+	valid := make([]bool, len(ref.entries))
+	if len(ref.entries) == 0 {
+		return true, valid
+	}
+
+	if ref.Policy != VerifyLegacy && ref.verifyCombination() {
+		for i := range valid {
+			valid[i] = true
+		}
+		return true, valid
+	}
+
+	// The batch failed, so find which entries are bad.
+	allValid := true
+	for i, entry := range ref.entries {
+		valid[i] = ref.signer(entry).Verify(entry.mess, entry.signature)
+		allValid = allValid && valid[i]
+	}
+
+	return allValid, valid
+}
+
+// verifyCombination checks 8 * (sum(z_i * R_i) + sum(z_i * h_i * A_i) - sum(z_i * S_i) * B) = 0.
+func (ref *Ed25519BatchVerifier) verifyCombination() bool {
+
+	// The points are R_0, A_0, R_1, A_1, ..., B.
+	points := make([]*Ed25519GroupElement, 0, 2*len(ref.entries)+1)
+	scalars := make([]*Ed25519EncodedFieldElement, 0, 2*len(ref.entries)+1)
+	zero := &Ed25519EncodedFieldElement{Ed25519FieldZeroShort(), Ed25519FieldZeroShort()}
+	sumS := zero
+	for _, entry := range ref.entries {
+		signer := ref.signer(entry)
+		A, R, h, err := signer.prepareVerification(signer.messageHash(nil, entry.mess), entry.signature)
+		if err != nil {
+			return false
+		}
+
+		z := make([]byte, 32)
+		_, err = io.ReadFull(rand.Reader, z[:batchEntryCoefficientSize])
+		if err != nil {
+			return false
+		}
+		zi := &Ed25519EncodedFieldElement{Ed25519FieldZeroShort(), z}
+
+		points = append(points, R, A)
+		scalars = append(scalars, zi, h.multiplyAndAddModQ(zi, zero))
+		sumS = zi.multiplyAndAddModQ(&Ed25519EncodedFieldElement{Ed25519FieldZeroShort(), entry.signature.S}, sumS)
+	}
+
+	points = append(points, Ed25519Group.BASE_POINT())
+	scalars = append(scalars, negateModQ(sumS))
+
+	sum, err := multiScalarMultiplyVariableTime(points, scalars)
+	if err != nil {
+		return false
+	}
+
+	return sum.multiplyByCofactor().isNeutral()
+}
+
+// signer creates the signer that verifies entry on its own.
+func (ref *Ed25519BatchVerifier) signer(entry *batchEntry) *Ed25519DsaSigner {
+
+	return &Ed25519DsaSigner{&KeyPair{nil, entry.publicKey}, ref.Policy, ref.schema}
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// batchPolicies are the policies the batch equation verifies.
+var batchPolicies = []VerificationPolicy{VerifyStrict, VerifyZip215}
+
+func newSignedBatch(t assert.TestingT, size int, policy VerificationPolicy) (*Ed25519BatchVerifier, []*KeyPair, [][]byte, []*Signature) {
+	batch := NewEd25519BatchVerifier(policy)
+	keyPairs := make([]*KeyPair, size)
+	messages := make([][]byte, size)
+	signatures := make([]*Signature, size)
+	for i := 0; i < size; i++ {
+		kp, err := NewRandomKeyPair()
+		assert.Nil(t, err)
+		mess := []byte(fmt.Sprintf("transaction #%d", i))
+		signature, err := NewEd25519DsaSigner(kp).Sign(mess)
+		assert.Nil(t, err)
+
+		keyPairs[i], messages[i], signatures[i] = kp, mess, signature
+	}
+
+	return batch, keyPairs, messages, signatures
+}
+
+func TestEd25519BatchVerifier_EmptyBatchIsValid(t *testing.T) {
+	ok, valid := NewEd25519BatchVerifier(VerifyZip215).Verify()
+
+	assert.True(t, ok)
+	assert.Empty(t, valid)
+}
+
+func TestEd25519BatchVerifier_AcceptsValidSignatures(t *testing.T) {
+	for _, policy := range batchPolicies {
+		batch, keyPairs, messages, signatures := newSignedBatch(t, 16, policy)
+		for i := range keyPairs {
+			batch.Add(keyPairs[i].PublicKey, messages[i], signatures[i])
+		}
+
+		// the batch is accepted by the batch equation, not by the fallback
+		assert.Truef(t, batch.verifyCombination(), "policy %d", policy)
+		ok, valid := batch.Verify()
+
+		assert.True(t, ok)
+		assert.Equal(t, 16, batch.Len())
+		for i := range valid {
+			assert.Truef(t, valid[i], "entry %d must be valid (policy %d)", i, policy)
+		}
+	}
+}
+
+func TestEd25519BatchVerifier_FindsBadSignatures(t *testing.T) {
+	for _, policy := range batchPolicies {
+		batch, keyPairs, messages, signatures := newSignedBatch(t, 8, policy)
+		// wrong message
+		messages[2] = []byte("forged")
+		// signature from another key
+		signatures[5] = signatures[6]
+		for i := range keyPairs {
+			batch.Add(keyPairs[i].PublicKey, messages[i], signatures[i])
+		}
+
+		assert.Falsef(t, batch.verifyCombination(), "policy %d", policy)
+		ok, valid := batch.Verify()
+
+		assert.False(t, ok)
+		for i := range valid {
+			assert.Equalf(t, i != 2 && i != 5, valid[i], "entry %d (policy %d)", i, policy)
+		}
+	}
+}
+
+func TestEd25519BatchVerifier_MatchesSingleVerification(t *testing.T) {
+	batch, keyPairs, messages, signatures := newSignedBatch(t, 4, VerifyLegacy)
+	signatures[1].S[0] ^= 0x01
+	for i := range keyPairs {
+		batch.Add(keyPairs[i].PublicKey, messages[i], signatures[i])
+	}
+
+	_, valid := batch.Verify()
+
+	for i := range keyPairs {
+		expected := NewEd25519DsaSigner(keyPairs[i]).Verify(messages[i], signatures[i])
+		assert.Equalf(t, expected, valid[i], "entry %d", i)
+	}
+}
+
+func TestEd25519BatchVerifier_MatchesVerificationPolicyVectors(t *testing.T) {
+	for _, policy := range []VerificationPolicy{VerifyLegacy, VerifyStrict, VerifyZip215} {
+		batch := NewEd25519BatchVerifier(policy)
+		vectors := loadVerificationVectors(t)
+		for _, vector := range vectors {
+			publicKey, mess, signature := vector.decode(t)
+			batch.Add(publicKey, mess, signature)
+
+			// under VerifyStrict and VerifyZip215 a batch of one vector is decided by the batch equation,
+			// under VerifyLegacy by the verification of the entry
+			single := NewEd25519BatchVerifier(policy)
+			single.Add(publicKey, mess, signature)
+			if policy != VerifyLegacy {
+				assert.Equalf(t, vector.expected(policy), single.verifyCombination(), "%s combination (policy %d)", vector.Description, policy)
+			}
+			ok, _ := single.Verify()
+			assert.Equalf(t, vector.expected(policy), ok, "%s alone (policy %d)", vector.Description, policy)
+		}
+
+		_, valid := batch.Verify()
+
+		for i, vector := range vectors {
+			assert.Equalf(t, vector.expected(policy), valid[i], "%s (policy %d)", vector.Description, policy)
+		}
+	}
+}
+
+func TestEd25519BatchVerifier_AcceptsZip215Batch(t *testing.T) {
+	batch := NewEd25519BatchVerifier(VerifyZip215)
+	for _, vector := range loadVerificationVectors(t) {
+		if vector.Zip215 {
+			publicKey, mess, signature := vector.decode(t)
+			batch.Add(publicKey, mess, signature)
+		}
+	}
+
+	assert.True(t, batch.verifyCombination())
+}
+
+func TestEd25519BatchVerifier_CoefficientsDoNotUseEngineSeed(t *testing.T) {
+	_, keyPairs, messages, signatures := newSignedBatch(t, 4, VerifyZip215)
+	// the engine seed is exhausted, the coefficients are read from crypto/rand
+	batch := NewEd25519SeedCryptoEngine(bytes.NewReader(nil)).CreateBatchVerifier(VerifyZip215)
+	for i := range keyPairs {
+		batch.Add(keyPairs[i].PublicKey, messages[i], signatures[i])
+	}
+
+	assert.True(t, batch.verifyCombination())
+}
+
+func BenchmarkEd25519BatchVerifier_Verify(b *testing.B) {
+	for _, policy := range []VerificationPolicy{VerifyLegacy, VerifyStrict, VerifyZip215} {
+		batch, keyPairs, messages, signatures := newSignedBatch(b, 64, policy)
+		for i := range keyPairs {
+			batch.Add(keyPairs[i].PublicKey, messages[i], signatures[i])
+		}
+
+		b.Run(fmt.Sprintf("batch/policy=%d", policy), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				batch.Verify()
+			}
+		})
+		b.Run(fmt.Sprintf("serial/policy=%d", policy), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for i := range keyPairs {
+					signer := NewEd25519DsaSigner(keyPairs[i])
+					signer.Policy = policy
+					signer.Verify(messages[i], signatures[i])
+				}
+			}
+		})
+	}
+}
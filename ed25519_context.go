@@ -0,0 +1,117 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"errors"
+	"hash"
+)
+
+// maxContextSize is the longest context string dom2 can encode.
+const maxContextSize = 255
+
+// dom2Prefix starts every dom2 prefix of RFC 8032.
+var dom2Prefix = []byte("SigEd25519 no Ed25519 collisions")
+
+var (
+	// ErrContextTooLong is returned when the context string is longer than 255 bytes.
+	ErrContextTooLong = errors.New("context must not be longer than 255 bytes")
+	// ErrEmptyContext is returned when Ed25519ctx is used without a context string.
+	ErrEmptyContext = errors.New("Ed25519ctx requires a non empty context")
+	// ErrInvalidPrehash is returned when the prehashed message does not have the size of the prehash function.
+	ErrInvalidPrehash = errors.New("prehashed message has invalid size")
+)
+
+// dom2 returns the RFC 8032 prefix "SigEd25519 no Ed25519 collisions" || phflag || len(context) || context.
+func dom2(prehashed bool, context []byte) ([]byte, error) {
+
+	if len(context) > maxContextSize {
+		return nil, ErrContextTooLong
+	}
+
+	dom := make([]byte, 0, len(dom2Prefix)+2+len(context))
+	dom = append(dom, dom2Prefix...)
+	if prehashed {
+		dom = append(dom, 1)
+	} else {
+		dom = append(dom, 0)
+	}
+	dom = append(dom, byte(len(context)))
+
+	return append(dom, context...), nil
+}
+
+// SignContext signs mess with Ed25519ctx: the signature is only valid for the same non empty context.
+func (ref *Ed25519DsaSigner) SignContext(mess []byte, context []byte) (*Signature, error) {
+
+	dom, err := ref.contextDom(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.sign(ref.messageHash(dom, mess))
+}
+
+// VerifyContext checks an Ed25519ctx signature of mess under the verification policy of the signer.
+// It returns nil if the signature is valid and the reason of the rejection otherwise.
+func (ref *Ed25519DsaSigner) VerifyContext(mess []byte, context []byte, signature *Signature) error {
+
+	dom, err := ref.contextDom(context)
+	if err != nil {
+		return err
+	}
+
+	return ref.verify(ref.messageHash(dom, mess), signature)
+}
+
+// NewPrehash creates the prehash function of Ed25519ph, it is the hash function of the engine
+// (SHA3-512, SHA-512 or Keccak-512).
+// Messages of any size can be written to it and its Sum passed to SignPrehashed and VerifyPrehashed.
+func (ref *Ed25519DsaSigner) NewPrehash() hash.Hash {
+
+	return ref.schema.newHash()
+}
+
+// SignPrehashed signs with Ed25519ph the message digest created by NewPrehash.
+// context may be empty.
+func (ref *Ed25519DsaSigner) SignPrehashed(digest []byte, context []byte) (*Signature, error) {
+
+	dom, err := ref.prehashDom(digest, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref.sign(ref.messageHash(dom, digest))
+}
+
+// VerifyPrehashed checks an Ed25519ph signature of the message digest created by NewPrehash.
+// It returns nil if the signature is valid and the reason of the rejection otherwise.
+func (ref *Ed25519DsaSigner) VerifyPrehashed(digest []byte, context []byte, signature *Signature) error {
+
+	dom, err := ref.prehashDom(digest, context)
+	if err != nil {
+		return err
+	}
+
+	return ref.verify(ref.messageHash(dom, digest), signature)
+}
+
+func (ref *Ed25519DsaSigner) contextDom(context []byte) ([]byte, error) {
+
+	if len(context) == 0 {
+		return nil, ErrEmptyContext
+	}
+
+	return dom2(false, context)
+}
+
+func (ref *Ed25519DsaSigner) prehashDom(digest []byte, context []byte) ([]byte, error) {
+
+	if len(digest) != ref.schema.newHash().Size() {
+		return nil, ErrInvalidPrehash
+	}
+
+	return dom2(true, context)
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRfc8032Signer(t *testing.T, privateKeyHex string) *Ed25519DsaSigner {
+	privateKey, err := NewPrivateKeyfromHexString(privateKeyHex)
+	assert.Nil(t, err)
+	engine := CryptoEngines.Ed25519Sha512Engine
+	kp, err := NewKeyPair(privateKey, engine.CreateKeyGenerator().DerivePublicKey(privateKey), engine)
+	assert.Nil(t, err)
+
+	return engine.CreateDsaSigner(kp).(*Ed25519DsaSigner)
+}
+
+func TestEd25519DsaSigner_SignContextMatchesRFC8032(t *testing.T) {
+	// RFC 8032, section 7.2, Ed25519ctx TEST foo
+	signer := newRfc8032Signer(t, "0305334e381af78f141cb666f6199f57bc3495335a256a95bd2a55bf546663f6")
+	assert.Equal(t, "dfc9425e4f968f7f0c29f0259cf5f9aed6851c2bb4ad8bfb860cfee0ab248292", signer.KeyPair.PublicKey.hex())
+	mess, err := hex.DecodeString("f726936d19c800494e3fdaff20b276a8")
+	assert.Nil(t, err)
+
+	signature, err := signer.SignContext(mess, []byte("foo"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "55a4cc2f70a54e04288c5f4cd1e45a7bb520b36292911876cada7323198dd87a"+
+		"8b36950b95130022907a7fb7c4e9b2d5f6cca685a587b4b21f4b888e4e7edb0d", signature.String())
+	assert.Nil(t, signer.VerifyContext(mess, []byte("foo"), signature))
+	assert.Equal(t, ErrInvalidSignature, signer.VerifyContext(mess, []byte("bar"), signature))
+	assert.False(t, signer.Verify(mess, signature))
+}
+
+func TestEd25519DsaSigner_SignPrehashedMatchesRFC8032(t *testing.T) {
+	// RFC 8032, section 7.3, Ed25519ph TEST abc
+	signer := newRfc8032Signer(t, "833fe62409237b9d62ec77587520911e9a759cec1d19755b7da901b96dca3d42")
+	assert.Equal(t, "ec172b93ad5e563bf4932c70e1245034c35467ef2efd4d64ebf819683467e2bf", signer.KeyPair.PublicKey.hex())
+	prehash := signer.NewPrehash()
+	_, err := prehash.Write([]byte("abc"))
+	assert.Nil(t, err)
+	digest := prehash.Sum(nil)
+
+	signature, err := signer.SignPrehashed(digest, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "98a70222f0b8121aa9d30f813d683f809e462b469c7ff87639499bb94e6dae41"+
+		"31f85042463c2a355a2003d062adf5aaa10b8c61e636062aaad11c2a26083406", signature.String())
+	assert.Nil(t, signer.VerifyPrehashed(digest, nil, signature))
+	assert.Equal(t, ErrInvalidSignature, signer.VerifyPrehashed(digest, []byte("other"), signature))
+	assert.False(t, signer.Verify(digest, signature))
+}
+
+func TestEd25519DsaSigner_ModesAreSeparated(t *testing.T) {
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		kp, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+		signer := engine.CreateDsaSigner(kp).(*Ed25519DsaSigner)
+		prehash := signer.NewPrehash()
+		_, err = prehash.Write([]byte(message))
+		assert.Nil(t, err)
+		digest := prehash.Sum(nil)
+
+		pure, err := signer.Sign(digest)
+		assert.Nil(t, err)
+		ctx, err := signer.SignContext(digest, []byte("protocol"))
+		assert.Nil(t, err)
+		ph, err := signer.SignPrehashed(digest, []byte("protocol"))
+		assert.Nil(t, err)
+
+		assert.Nil(t, signer.VerifyContext(digest, []byte("protocol"), ctx))
+		assert.Nil(t, signer.VerifyPrehashed(digest, []byte("protocol"), ph))
+		assert.NotNil(t, signer.VerifyContext(digest, []byte("protocol"), pure))
+		assert.NotNil(t, signer.VerifyContext(digest, []byte("protocol"), ph))
+		assert.NotNil(t, signer.VerifyPrehashed(digest, []byte("protocol"), ctx))
+		assert.False(t, signer.Verify(digest, ctx))
+		assert.False(t, signer.Verify(digest, ph))
+	}
+}
+
+func TestEd25519DsaSigner_ContextValidation(t *testing.T) {
+	signer := NewEd25519DsaSigner(keyPair)
+	signature, err := signer.Sign([]byte(message))
+	assert.Nil(t, err)
+	tooLong := []byte(strings.Repeat("c", maxContextSize+1))
+
+	_, err = signer.SignContext([]byte(message), nil)
+	assert.Equal(t, ErrEmptyContext, err)
+	_, err = signer.SignContext([]byte(message), tooLong)
+	assert.Equal(t, ErrContextTooLong, err)
+	assert.Equal(t, ErrContextTooLong, signer.VerifyContext([]byte(message), tooLong, signature))
+
+	_, err = signer.SignPrehashed([]byte(message), nil)
+	assert.Equal(t, ErrInvalidPrehash, err)
+	assert.Equal(t, ErrInvalidPrehash, signer.VerifyPrehashed([]byte(message), nil, signature))
+
+	_, err = signer.SignContext([]byte(message), []byte(strings.Repeat("c", maxContextSize)))
+	assert.Nil(t, err)
+}
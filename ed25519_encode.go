@@ -6,18 +6,79 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/sha512"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"math/big"
 
 	"github.com/proximax-storage/go-xpx-utils"
+	"golang.org/x/crypto/sha3"
+)
+
+// ed25519Schema is the set of hash functions an Ed25519 variant derives keys and signs with.
+type ed25519Schema struct {
+	// hash is used for the private key expansion, the nonce r and the challenge h.
+	hash func(inputs ...[]byte) ([]byte, error)
+	// sharedKeyHash derives the block cipher key from the shared point.
+	sharedKeyHash func(b []byte) ([]byte, error)
+	// reversedPrivateKey is set when the private key bytes are hashed in reversed order (NIS1).
+	reversedPrivateKey bool
+	// newHash creates the streaming form of hash, it is the prehash function of Ed25519ph.
+	newHash func() hash.Hash
+}
+
+var (
+	// ed25519Sha3Schema is the Catapult variant of Ed25519 that uses SHA3-512.
+	ed25519Sha3Schema = &ed25519Schema{HashesSha3_512, HashesSha3_256, false, sha3.New512}
+	// ed25519Sha512Schema is the RFC 8032 variant of Ed25519 that uses SHA-512.
+	ed25519Sha512Schema = &ed25519Schema{HashesSha_512, HashesSha3_256, false, sha512.New}
+	// ed25519KeccakSchema is the NEM NIS1 variant of Ed25519 that uses legacy Keccak-512.
+	ed25519KeccakSchema = &ed25519Schema{HashesKeccak_512, HashesKeccak_256, true, sha3.NewLegacyKeccak512}
 )
 
 // PrepareForScalarMultiply precomputes the encoded group elements
 func PrepareForScalarMultiply(key *PrivateKey) *Ed25519EncodedFieldElement {
 
-	hash, err := HashesSha3_512(key.Raw)
+	return ed25519Sha3Schema.prepareForScalarMultiply(key)
+}
+
+// ed25519GroupOrderBytes is the little endian encoding of Ed25519Group.GROUP_ORDER.
+var ed25519GroupOrderBytes = [32]byte{
+	0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+}
+
+// isCanonicalScalar reports whether the little endian scalar s is reduced modulo the group order.
+// All 256 bits of s are compared with the group order in constant time:
+// s is canonical if subtracting the group order from it borrows.
+func isCanonicalScalar(s []byte) bool {
+
+	if len(s) != len(ed25519GroupOrderBytes) {
+		return false
+	}
+
+	borrow := 0
+	for i, l := range ed25519GroupOrderBytes {
+		borrow = ((int(s[i]) - int(l) - borrow) >> 8) & 1
+	}
+
+	return borrow == 1
+}
+
+// negateModQ returns (group order - a) mod group order.
+func negateModQ(a *Ed25519EncodedFieldElement) *Ed25519EncodedFieldElement {
+
+	b := MathUtils.BytesToBigInteger(a.Raw)
+	b.Sub(Ed25519Group.GROUP_ORDER, b).Mod(b, Ed25519Group.GROUP_ORDER)
+	return MathUtils.ToEncodedFieldElement(b)
+}
+
+// prepareForScalarMultiply clamps the lower 32 bytes of the private key hash
+func (ref *ed25519Schema) prepareForScalarMultiply(key *PrivateKey) *Ed25519EncodedFieldElement {
+
+	hash, err := ref.hashPrivateKey(key)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -28,6 +89,19 @@ func PrepareForScalarMultiply(key *PrivateKey) *Ed25519EncodedFieldElement {
 	return &Ed25519EncodedFieldElement{Ed25519FieldZeroShort(), a}
 }
 
+// hashPrivateKey expands the private key into the scalar (lower 32 bytes) and the nonce prefix (upper 32 bytes)
+func (ref *ed25519Schema) hashPrivateKey(key *PrivateKey) ([]byte, error) {
+
+	if !ref.reversedPrivateKey {
+		return ref.hash(key.Raw)
+	}
+
+	reversed := make([]byte, len(key.Raw))
+	copy(reversed, key.Raw)
+	utils.ReverseByteArray(reversed)
+	return ref.hash(reversed)
+}
+
 // Ed25519KeyAnalyzer implement operations key properties
 type Ed25519KeyAnalyzer struct {
 }
@@ -1886,6 +1960,25 @@ func (ref *Ed25519EncodedGroupElement) GetAffineX() (*Ed25519FieldElement, error
 	return encoded.Decode(), nil
 }
 
+// IsCanonical reports whether ref is the canonical encoding of a point on the curve:
+// the y-coordinate is reduced modulo p and the sign bit is not set when x is 0.
+func (ref *Ed25519EncodedGroupElement) IsCanonical() bool {
+
+	y := make([]byte, len(ref.Raw))
+	copy(y, ref.Raw)
+	y[len(y)-1] &= 0x7F
+	if MathUtils.BytesToBigInteger(y).Cmp(Ed25519Field.P) >= 0 {
+		return false
+	}
+
+	x, err := ref.GetAffineX()
+	if err != nil {
+		return false
+	}
+
+	return x.IsNonZero() || !utils.GetBitToBool(ref.Raw, 255)
+}
+
 // Equals compares two Ed25519EncodedGroupElement with constant time
 func (ref *Ed25519EncodedGroupElement) Equals(ge *Ed25519EncodedGroupElement) bool {
 
@@ -2145,8 +2238,6 @@ func (ref *Ed25519GroupElement) Equals(ge *Ed25519GroupElement) (res bool) {
 	default:
 		panic(errors.New("not supportet coor type"))
 	}
-	return false
-
 }
 
 // Convert a to 2^16 bit representation.
@@ -2415,7 +2506,6 @@ func (ref *Ed25519GroupElement) toCoordinateSystem(newCoordinateSystem Coordinat
 	default:
 		panic(fmt.Errorf("NewIllegalArgumentException(%d)", ref.coordinateSystem))
 	}
-	return nil
 }
 
 // make copy ref object
@@ -2879,6 +2969,96 @@ func (ref *Ed25519GroupElement) doubleScalarMultiplyVariableTime(
 	return r, nil
 }
 
+// oddMultiplesCached returns the table [ref, 3 * ref, 5 * ref, ..., 15 * ref] in CACHED coordinate system.
+// * Unlike PrecomputeForDoubleScalarMultiplication no field inversion is needed,
+// * which keeps the table cheap enough to be built for every point of a multi scalar multiplication.
+// *
+// * @return The 8 odd multiples of ref.
+func (ref *Ed25519GroupElement) oddMultiplesCached() []*Ed25519GroupElement {
+
+	P := ref.toP3()
+	twoP := P.dbl().toP3().toCached()
+	multiples := make([]*Ed25519GroupElement, 8)
+	multiples[0] = P.toCached()
+	for i := 1; i < len(multiples); i++ {
+		P = P.add(twoP).toP3()
+		multiples[i] = P.toCached()
+	}
+
+	return multiples
+}
+
+// multiScalarMultiplyVariableTime r = a[0] * A[0] + a[1] * A[1] + ... + a[n-1] * A[n-1] where
+// * a[i] are encoded field elements and
+// * A[i] are group elements in P3 coordinate system.
+// * The doublings are shared between all points (Straus' method), so it is much cheaper
+// * than n separate scalar multiplications.
+// * Not constant time.
+// *
+// * @param A The group elements.
+// * @param a The encoded field elements, one for each group element.
+// * @return The resulting group element in P2 coordinate system.
+func multiScalarMultiplyVariableTime(
+	A []*Ed25519GroupElement,
+	a []*Ed25519EncodedFieldElement) (*Ed25519GroupElement, error) {
+	if len(A) != len(a) {
+		return nil, errors.New("number of group elements and field elements must be equal")
+	}
+
+	slides := make([][]int8, len(a))
+	multiples := make([][]*Ed25519GroupElement, len(A))
+	for j := range a {
+		slides[j] = A[j].slide(a[j])
+		multiples[j] = A[j].oddMultiplesCached()
+	}
+
+	r := Ed25519Group.ZERO_P2()
+	flag := false
+	for i := 255; i >= 0; i-- {
+		for j := 0; !flag && j < len(slides); j++ {
+			flag = slides[j][i] != 0
+		}
+		if flag {
+
+			t := r.dbl()
+			for j, aSlide := range slides {
+				if aSlide[i] > 0 {
+					t = t.toP3().add(multiples[j][aSlide[i]/2])
+				} else if aSlide[i] < 0 {
+					t = t.toP3().subtract(multiples[j][(-aSlide[i])/2])
+				}
+			}
+
+			r = t.toP2()
+		}
+
+	}
+
+	return r, nil
+}
+
+// multiplyByCofactor returns 8 * ref in P2 coordinate system.
+func (ref *Ed25519GroupElement) multiplyByCofactor() *Ed25519GroupElement {
+
+	return ref.dbl().toP2().dbl().toP2().dbl().toP2()
+}
+
+// hasSmallOrder reports whether ref is one of the 8 points whose order divides the cofactor.
+func (ref *Ed25519GroupElement) hasSmallOrder() bool {
+
+	return ref.multiplyByCofactor().isNeutral()
+}
+
+// isNeutral reports whether ref is the neutral element (0, 1) of the group.
+func (ref *Ed25519GroupElement) isNeutral() bool {
+
+	switch ref.coordinateSystem {
+	case P2, P3:
+		return !ref.X.IsNonZero() && ref.Y.Equals(ref.Z)
+	}
+	return ref.toP2().isNeutral()
+}
+
 // SatisfiesCurveEquation Verify that the group element satisfies the curve equation.
 // * @return true if the group element satisfies the curve equation, false otherwise.
 func (ref *Ed25519GroupElement) SatisfiesCurveEquation() bool {
@@ -0,0 +1,282 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// The envelope format encrypts a message once for many recipients:
+//
+//	"XPXE" | version | suite | salt | recipient count(2) | recipient slots | nonce | ciphertext and tag | authenticators
+//
+// The message is encrypted with the AEAD of the suite under a random content key.
+// Every recipient slot is fingerprint | nonce | content key | authentication key encrypted with the AEAD of the suite under
+// a key derived with HKDF-SHA256 from the ECDH shared secret of the sender and the recipient,
+// the info binds it to both public keys and the index of the slot. All slots are authenticated
+// together with the message, so recipients cannot be added, removed or replaced.
+// Every recipient learns the content key, so the message alone does not authenticate the sender.
+// The authenticator of a recipient is HMAC-SHA256 of everything before the authenticators under
+// the random authentication key of its slot, which only the sender and that recipient know,
+// so a recipient cannot re-encrypt the envelope for the others in the name of the sender.
+const (
+	// EnvelopeFingerprintSize is the size of the recipient fingerprint of a slot.
+	EnvelopeFingerprintSize = 8
+	// envelopeFormatVersion is the version of the envelope format.
+	envelopeFormatVersion = 1
+	// envelopeContentKeySize is the size of the content key.
+	envelopeContentKeySize = 32
+	// envelopeTagSize is the size of the tags of the AEADs of all suites.
+	envelopeTagSize = 16
+	// envelopeAuthenticationKeySize is the size of the authentication key of a slot.
+	envelopeAuthenticationKeySize = 32
+	// envelopeAuthenticatorSize is the size of the HMAC-SHA256 authenticator of a recipient.
+	envelopeAuthenticatorSize = sha256.Size
+)
+
+var (
+	// envelopeMagic starts every envelope.
+	envelopeMagic = []byte("XPXE")
+	// envelopePrefixSize is the size of "XPXE" | version | suite | salt.
+	envelopePrefixSize = len(envelopeMagic) + 2 + aeadSaltSize
+	// envelopeSlotKeyInfo starts the HKDF info of the slot keys.
+	envelopeSlotKeyInfo = []byte("xpx-crypto envelope slot")
+)
+
+var (
+	// ErrNoEnvelopeRecipients is returned when an envelope is encrypted without recipients.
+	ErrNoEnvelopeRecipients = errors.New("envelope has no recipients")
+	// ErrTooManyEnvelopeRecipients is returned when an envelope is encrypted for more than 65535 recipients.
+	ErrTooManyEnvelopeRecipients = errors.New("envelope has too many recipients")
+	// ErrNotEnvelopeRecipient is returned when the envelope has no slot for the recipient.
+	ErrNotEnvelopeRecipient = errors.New("envelope is not encrypted for the recipient")
+)
+
+// EnvelopeFingerprint returns the fingerprint that identifies the slot of publicKey in an envelope:
+// the first 8 bytes of SHA3-256 of the public key.
+func EnvelopeFingerprint(publicKey *PublicKey) ([]byte, error) {
+
+	hash, err := HashesSha3_256(publicKey.Raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash[:EnvelopeFingerprintSize], nil
+}
+
+// EnvelopeRecipients returns the fingerprints of the recipients of an envelope, in the order of the slots.
+func EnvelopeRecipients(input []byte) ([][]byte, error) {
+
+	suite, slots, _, _, err := parseEnvelope(input)
+	if err != nil {
+		return nil, err
+	}
+
+	slotSize := envelopeSlotSize(suite)
+	fingerprints := make([][]byte, 0, len(slots)/slotSize)
+	for i := 0; i < len(slots); i += slotSize {
+		fingerprint := make([]byte, EnvelopeFingerprintSize)
+		copy(fingerprint, slots[i:])
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	return fingerprints, nil
+}
+
+// EncryptEnvelope encrypts input from the sender of the block cipher to every one of recipients.
+// The recipient of the block cipher is not used. It does not depend on Mode.
+func (ref *Ed25519BlockCipher) EncryptEnvelope(input []byte, recipients []*PublicKey) ([]byte, error) {
+
+	if !ref.Suite.isKnown() {
+		return nil, ErrUnknownCipherSuite
+	}
+	if len(recipients) == 0 {
+		return nil, ErrNoEnvelopeRecipients
+	}
+	if len(recipients) > math.MaxUint16 {
+		return nil, ErrTooManyEnvelopeRecipients
+	}
+
+	salt := make([]byte, aeadSaltSize)
+	_, err := io.ReadFull(ref.seed, salt)
+	if err != nil {
+		return nil, err
+	}
+	contentKey := make([]byte, envelopeContentKeySize)
+	_, err = io.ReadFull(ref.seed, contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := ref.Suite.nonceSize()
+	headerSize := envelopePrefixSize + 2 + len(recipients)*envelopeSlotSize(ref.Suite)
+	header := make([]byte, 0, headerSize+nonceSize+len(input)+envelopeTagSize+len(recipients)*envelopeAuthenticatorSize)
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeFormatVersion, byte(ref.Suite))
+	header = append(header, salt...)
+	header = append(header, 0, 0)
+	binary.BigEndian.PutUint16(header[envelopePrefixSize:], uint16(len(recipients)))
+
+	authenticationKeys := make([][]byte, len(recipients))
+	for i, recipient := range recipients {
+		keys := make([]byte, envelopeContentKeySize+envelopeAuthenticationKeySize)
+		copy(keys, contentKey)
+		_, err = io.ReadFull(ref.seed, keys[envelopeContentKeySize:])
+		if err != nil {
+			return nil, err
+		}
+		authenticationKeys[i] = keys[envelopeContentKeySize:]
+		fingerprint, err := EnvelopeFingerprint(recipient)
+		if err != nil {
+			return nil, err
+		}
+		info := envelopeSlotKeyInfoOf(ref.senderKeyPair.PublicKey, recipient, i)
+		aead, err := ref.newAead(ref.senderKeyPair.PrivateKey, recipient, salt, info, ref.Suite)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, nonceSize)
+		_, err = io.ReadFull(ref.seed, nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		header = append(header, fingerprint...)
+		header = append(header, nonce...)
+		header = aead.Seal(header, nonce, keys, aeadAssociatedData(header[:envelopePrefixSize], fingerprint))
+	}
+
+	nonce := make([]byte, nonceSize)
+	_, err = io.ReadFull(ref.seed, nonce)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := ref.Suite.newAead(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	envelope := aead.Seal(header, nonce, input, header[:headerSize])
+
+	authenticated := envelope
+	for _, authenticationKey := range authenticationKeys {
+		envelope = append(envelope, envelopeAuthenticator(authenticationKey, authenticated)...)
+	}
+
+	return envelope, nil
+}
+
+// DecryptEnvelope decrypts an envelope from the sender of the block cipher with the recipient private key.
+// The slot of the recipient is found by its fingerprint, ErrNotEnvelopeRecipient is returned when there is none.
+// The cipher suite is read from the envelope, Suite is not used.
+func (ref *Ed25519BlockCipher) DecryptEnvelope(input []byte) ([]byte, error) {
+
+	suite, slots, payload, authenticators, err := parseEnvelope(input)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := EnvelopeFingerprint(ref.recipientKeyPair.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := input[:envelopePrefixSize]
+	salt := prefix[len(envelopeMagic)+2:]
+	nonceSize := suite.nonceSize()
+	slotSize := envelopeSlotSize(suite)
+	found := false
+	var keys, authenticator []byte
+	for i := 0; i < len(slots) && keys == nil; i += slotSize {
+		slot := slots[i : i+slotSize]
+		if !bytes.Equal(slot[:EnvelopeFingerprintSize], fingerprint) {
+			continue
+		}
+		found = true
+		info := envelopeSlotKeyInfoOf(ref.senderKeyPair.PublicKey, ref.recipientKeyPair.PublicKey, i/slotSize)
+		slotAead, err := ref.newAead(ref.recipientKeyPair.PrivateKey, ref.senderKeyPair.PublicKey, salt, info, suite)
+		if err != nil {
+			return nil, err
+		}
+		// fingerprints may collide, the slot that opens is the recipient's
+		nonce := slot[EnvelopeFingerprintSize : EnvelopeFingerprintSize+nonceSize]
+		keys, _ = slotAead.Open(nil, nonce, slot[EnvelopeFingerprintSize+nonceSize:], aeadAssociatedData(prefix, fingerprint))
+		authenticator = authenticators[i/slotSize*envelopeAuthenticatorSize:][:envelopeAuthenticatorSize]
+	}
+	if !found {
+		return nil, ErrNotEnvelopeRecipient
+	}
+	if keys == nil {
+		return nil, ErrDecryptionFailed
+	}
+	authenticated := input[:len(input)-len(authenticators)]
+	if !hmac.Equal(authenticator, envelopeAuthenticator(keys[envelopeContentKeySize:], authenticated)) {
+		return nil, ErrDecryptionFailed
+	}
+
+	aead, err := suite.newAead(keys[:envelopeContentKeySize])
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(authenticated) - len(payload)
+	plaintext, err := aead.Open(nil, payload[:nonceSize], payload[nonceSize:], input[:headerSize])
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// envelopeSlotKeyInfoOf is "xpx-crypto envelope slot" | sender public key | recipient public key | slot index(2).
+func envelopeSlotKeyInfoOf(sender *PublicKey, recipient *PublicKey, index int) []byte {
+
+	info := make([]byte, 0, len(envelopeSlotKeyInfo)+len(sender.Raw)+len(recipient.Raw)+2)
+	info = append(info, envelopeSlotKeyInfo...)
+	info = append(info, sender.Raw...)
+	info = append(info, recipient.Raw...)
+	return append(info, byte(index>>8), byte(index))
+}
+
+// envelopeAuthenticator is HMAC-SHA256 of the authenticated part of an envelope.
+func envelopeAuthenticator(authenticationKey []byte, authenticated []byte) []byte {
+
+	mac := hmac.New(sha256.New, authenticationKey)
+	mac.Write(authenticated)
+	return mac.Sum(nil)
+}
+
+// envelopeSlotSize returns the size of a recipient slot of suite.
+func envelopeSlotSize(suite CipherSuite) int {
+
+	return EnvelopeFingerprintSize + suite.nonceSize() + envelopeContentKeySize + envelopeAuthenticationKeySize + envelopeTagSize
+}
+
+// parseEnvelope checks the header of an envelope and returns its cipher suite, its recipient slots,
+// its nonce | ciphertext and its authenticators.
+func parseEnvelope(input []byte) (CipherSuite, []byte, []byte, []byte, error) {
+
+	if len(input) < len(envelopeMagic)+2 || !bytes.Equal(input[:len(envelopeMagic)], envelopeMagic) ||
+		input[len(envelopeMagic)] != envelopeFormatVersion || !CipherSuite(input[len(envelopeMagic)+1]).isKnown() {
+		return 0, nil, nil, nil, ErrUnsupportedCipherFormat
+	}
+	suite := CipherSuite(input[len(envelopeMagic)+1])
+	if len(input) < envelopePrefixSize+2 {
+		return 0, nil, nil, nil, ErrDecryptionFailed
+	}
+
+	count := int(binary.BigEndian.Uint16(input[envelopePrefixSize:]))
+	slotsEnd := envelopePrefixSize + 2 + count*envelopeSlotSize(suite)
+	payloadEnd := len(input) - count*envelopeAuthenticatorSize
+	if count == 0 || payloadEnd < slotsEnd+suite.nonceSize()+envelopeTagSize {
+		return 0, nil, nil, nil, ErrDecryptionFailed
+	}
+
+	return suite, input[envelopePrefixSize+2 : slotsEnd], input[slotsEnd:payloadEnd], input[payloadEnd:], nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEnvelopeKeyPairs(t *testing.T, count int) []*KeyPair {
+	keyPairs := make([]*KeyPair, count)
+	for i := range keyPairs {
+		kp, err := NewRandomKeyPair()
+		assert.Nil(t, err)
+		keyPairs[i] = kp
+	}
+
+	return keyPairs
+}
+
+func publicKeysOf(keyPairs []*KeyPair) []*PublicKey {
+	publicKeys := make([]*PublicKey, len(keyPairs))
+	for i, kp := range keyPairs {
+		publicKeys[i] = kp.PublicKey
+	}
+
+	return publicKeys
+}
+
+func TestEd25519BlockCipher_EnvelopeRoundTrip(t *testing.T) {
+	var _ EnvelopeBlockCipher = (*Ed25519BlockCipher)(nil)
+
+	sender := newEnvelopeKeyPairs(t, 1)[0]
+	recipients := newEnvelopeKeyPairs(t, 5)
+	for _, suite := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+		encrypter := NewEd25519BlockCipher(sender, sender, nil)
+		encrypter.Suite = suite
+		encrypted, err := encrypter.EncryptEnvelope([]byte(message), publicKeysOf(recipients))
+		assert.Nil(t, err)
+		assert.Equal(t, byte(suite), encrypted[len(envelopeMagic)+1])
+		assert.Len(t, encrypted, envelopePrefixSize+2+5*envelopeSlotSize(suite)+suite.nonceSize()+len(message)+envelopeTagSize+5*envelopeAuthenticatorSize)
+
+		// the suite is read from the envelope
+		for _, recipient := range recipients {
+			decrypted, err := NewEd25519BlockCipher(sender, recipient, nil).DecryptEnvelope(encrypted)
+			assert.Nil(t, err)
+			assert.Equal(t, message, string(decrypted))
+		}
+
+		fingerprints, err := EnvelopeRecipients(encrypted)
+		assert.Nil(t, err)
+		assert.Len(t, fingerprints, 5)
+	}
+}
+
+func TestEnvelopeRecipients(t *testing.T) {
+	sender := newEnvelopeKeyPairs(t, 1)[0]
+	recipients := newEnvelopeKeyPairs(t, 3)
+	encrypted, err := NewEd25519BlockCipher(sender, sender, nil).EncryptEnvelope([]byte(message), publicKeysOf(recipients))
+	assert.Nil(t, err)
+
+	fingerprints, err := EnvelopeRecipients(encrypted)
+	assert.Nil(t, err)
+	assert.Len(t, fingerprints, 3)
+	for i, recipient := range recipients {
+		fingerprint, err := EnvelopeFingerprint(recipient.PublicKey)
+		assert.Nil(t, err)
+		assert.Equal(t, fingerprint, fingerprints[i])
+	}
+
+	publicKey, err := NewPublicKeyfromHex(addressTestPublicKey)
+	assert.Nil(t, err)
+	fingerprint, err := EnvelopeFingerprint(publicKey)
+	assert.Nil(t, err)
+	// SHA3-256 of the public key is the first step of the address derivation
+	hash, err := HashesSha3_256(publicKey.Raw)
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(hash[:8]), hex.EncodeToString(fingerprint))
+}
+
+func TestEd25519BlockCipher_EnvelopeRejectsOthers(t *testing.T) {
+	keyPairs := newEnvelopeKeyPairs(t, 4)
+	sender, recipient, outsider, impostor := keyPairs[0], keyPairs[1], keyPairs[2], keyPairs[3]
+	encrypted, err := NewEd25519BlockCipher(sender, sender, nil).EncryptEnvelope([]byte(message), []*PublicKey{recipient.PublicKey})
+	assert.Nil(t, err)
+
+	_, err = NewEd25519BlockCipher(sender, outsider, nil).DecryptEnvelope(encrypted)
+	assert.Equal(t, ErrNotEnvelopeRecipient, err)
+
+	// the slot of the recipient does not open with another sender
+	_, err = NewEd25519BlockCipher(impostor, recipient, nil).DecryptEnvelope(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_EnvelopeDetectsTampering(t *testing.T) {
+	slotSize, nonceSize := envelopeSlotSize(CipherSuiteAes256Gcm), CipherSuiteAes256Gcm.nonceSize()
+	keyPairs := newEnvelopeKeyPairs(t, 3)
+	sender, recipients := keyPairs[0], keyPairs[1:]
+	blockCipher := NewEd25519BlockCipher(sender, recipients[0], nil)
+	encrypted, err := blockCipher.EncryptEnvelope([]byte(message), publicKeysOf(recipients))
+	assert.Nil(t, err)
+
+	slotsOffset := envelopePrefixSize + 2
+	payloadEnd := len(encrypted) - 2*envelopeAuthenticatorSize
+	for _, i := range []int{
+		len(envelopeMagic) + 2,                          // salt
+		slotsOffset + EnvelopeFingerprintSize,           // nonce of the own slot
+		slotsOffset + slotSize - 1,                      // tag of the own slot
+		slotsOffset + slotSize + slotSize/2,             // slot of another recipient
+		payloadEnd - len(message) - envelopeTagSize - 1, // payload nonce
+		payloadEnd - 1,                                  // payload tag
+		payloadEnd,                                      // own authenticator
+	} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+
+		_, err = blockCipher.DecryptEnvelope(tampered)
+		assert.Equalf(t, ErrDecryptionFailed, err, "byte %d", i)
+	}
+
+	// a recipient cannot be removed
+	removed := append([]byte{}, encrypted[:slotsOffset+slotSize]...)
+	removed = append(removed, encrypted[slotsOffset+2*slotSize:]...)
+	binary.BigEndian.PutUint16(removed[envelopePrefixSize:], 1)
+	_, err = blockCipher.DecryptEnvelope(removed)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	// the slot keys are bound to the index of the slot
+	swapped := append([]byte{}, encrypted...)
+	copy(swapped[slotsOffset:], encrypted[slotsOffset+slotSize:slotsOffset+2*slotSize])
+	copy(swapped[slotsOffset+slotSize:], encrypted[slotsOffset:slotsOffset+slotSize])
+	info := envelopeSlotKeyInfoOf(sender.PublicKey, recipients[0].PublicKey, 1)
+	slotAead, err := blockCipher.newAead(recipients[0].PrivateKey, sender.PublicKey, encrypted[len(envelopeMagic)+2:envelopePrefixSize], info, CipherSuiteAes256Gcm)
+	assert.Nil(t, err)
+	slot := swapped[slotsOffset+slotSize : slotsOffset+2*slotSize]
+	fingerprint, err := EnvelopeFingerprint(recipients[0].PublicKey)
+	assert.Nil(t, err)
+	_, err = slotAead.Open(nil, slot[EnvelopeFingerprintSize:EnvelopeFingerprintSize+nonceSize], slot[EnvelopeFingerprintSize+nonceSize:], aeadAssociatedData(encrypted[:envelopePrefixSize], fingerprint))
+	assert.NotNil(t, err)
+	_, err = blockCipher.DecryptEnvelope(swapped)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	_, err = blockCipher.DecryptEnvelope(encrypted[:slotsOffset+2*slotSize+nonceSize+envelopeTagSize+2*envelopeAuthenticatorSize-1])
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = blockCipher.DecryptEnvelope(encrypted[:slotsOffset-1])
+	assert.Equal(t, ErrDecryptionFailed, err)
+	for _, i := range []int{0, len(envelopeMagic), len(envelopeMagic) + 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] = 0xff
+
+		_, err = blockCipher.DecryptEnvelope(tampered)
+		assert.Equal(t, ErrUnsupportedCipherFormat, err)
+	}
+	// the suite is authenticated
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(envelopeMagic)+1] = byte(CipherSuiteChaCha20Poly1305)
+	_, err = blockCipher.DecryptEnvelope(tampered)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_EnvelopeRecipientCannotImpersonateSender(t *testing.T) {
+	slotSize, nonceSize := envelopeSlotSize(CipherSuiteAes256Gcm), CipherSuiteAes256Gcm.nonceSize()
+	keyPairs := newEnvelopeKeyPairs(t, 3)
+	sender, recipients := keyPairs[0], keyPairs[1:]
+	encrypted, err := NewEd25519BlockCipher(sender, sender, nil).EncryptEnvelope([]byte(message), publicKeysOf(recipients))
+	assert.Nil(t, err)
+
+	// the second recipient opens its slot and encrypts another message under the content key
+	slotsOffset := envelopePrefixSize + 2
+	slot := encrypted[slotsOffset+slotSize : slotsOffset+2*slotSize]
+	info := envelopeSlotKeyInfoOf(sender.PublicKey, recipients[1].PublicKey, 1)
+	blockCipher := NewEd25519BlockCipher(sender, recipients[1], nil)
+	slotAead, err := blockCipher.newAead(recipients[1].PrivateKey, sender.PublicKey, encrypted[len(envelopeMagic)+2:envelopePrefixSize], info, CipherSuiteAes256Gcm)
+	assert.Nil(t, err)
+	fingerprint, err := EnvelopeFingerprint(recipients[1].PublicKey)
+	assert.Nil(t, err)
+	keys, err := slotAead.Open(nil, slot[EnvelopeFingerprintSize:EnvelopeFingerprintSize+nonceSize], slot[EnvelopeFingerprintSize+nonceSize:], aeadAssociatedData(encrypted[:envelopePrefixSize], fingerprint))
+	assert.Nil(t, err)
+
+	headerSize := slotsOffset + 2*slotSize
+	forged := append([]byte{}, encrypted[:headerSize+nonceSize]...)
+	aead, err := newAes256Gcm(keys[:envelopeContentKeySize])
+	assert.Nil(t, err)
+	forged = aead.Seal(forged, forged[headerSize:], []byte("forged message"), forged[:headerSize])
+	authenticated := forged
+	forged = append(forged, encrypted[len(encrypted)-2*envelopeAuthenticatorSize:len(encrypted)-envelopeAuthenticatorSize]...)
+	forged = append(forged, envelopeAuthenticator(keys[envelopeContentKeySize:], authenticated)...)
+
+	decrypted, err := blockCipher.DecryptEnvelope(forged)
+	assert.Nil(t, err)
+	assert.Equal(t, "forged message", string(decrypted))
+	_, err = NewEd25519BlockCipher(sender, recipients[0], nil).DecryptEnvelope(forged)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_EnvelopeErrors(t *testing.T) {
+	sender := newEnvelopeKeyPairs(t, 1)[0]
+	blockCipher := NewEd25519BlockCipher(sender, sender, nil)
+
+	_, err := blockCipher.EncryptEnvelope([]byte(message), nil)
+	assert.Equal(t, ErrNoEnvelopeRecipients, err)
+	_, err = blockCipher.EncryptEnvelope([]byte(message), make([]*PublicKey, 1<<16))
+	assert.Equal(t, ErrTooManyEnvelopeRecipients, err)
+
+	smallOrder, err := hex.DecodeString("0100000000000000000000000000000000000000000000000000000000000000")
+	assert.Nil(t, err)
+	_, err = blockCipher.EncryptEnvelope([]byte(message), []*PublicKey{sender.PublicKey, NewPublicKey(smallOrder)})
+	assert.Equal(t, ErrInvalidSharedSecret, err)
+}
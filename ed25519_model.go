@@ -8,8 +8,8 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/subtle"
 	"errors"
-	"fmt"
 	"io"
 )
 
@@ -18,6 +18,12 @@ type Ed25519SeedCryptoEngine struct {
 	seed io.Reader
 }
 
+// NewEd25519SeedCryptoEngine creates an engine that reads key material, salts and IVs from seed.
+// if seed is nil - use crypto/rand instead
+func NewEd25519SeedCryptoEngine(seed io.Reader) *Ed25519SeedCryptoEngine {
+	return &Ed25519SeedCryptoEngine{seed}
+}
+
 // CreateDsaSigner implemented interface CryptoEngine method
 func (ref *Ed25519SeedCryptoEngine) CreateDsaSigner(keyPair *KeyPair) DsaSigner {
 	return NewEd25519DsaSigner(keyPair)
@@ -38,12 +44,144 @@ func (ref *Ed25519SeedCryptoEngine) CreateKeyAnalyzer() KeyAnalyzer {
 	return NewEd25519KeyAnalyzer()
 }
 
+// Seed implemented interface SeededEngine method
+func (ref *Ed25519SeedCryptoEngine) Seed() io.Reader {
+	return ref.seed
+}
+
+// GetCurve implemented interface CryptoEngine method
+func (ref *Ed25519SeedCryptoEngine) GetCurve() Curve {
+	return NewEd25519Curve()
+}
+
+// CreateBatchVerifier creates a batch verifier for signatures of this engine that accepts entries by policy
+func (ref *Ed25519SeedCryptoEngine) CreateBatchVerifier(policy VerificationPolicy) *Ed25519BatchVerifier {
+	return NewEd25519BatchVerifier(policy)
+}
+
+// Ed25519Sha512SeedCryptoEngine wraps a cryptographic engine ed25519 as specified by RFC 8032 (SHA-512)
+// and seed for this engine.
+// Its signatures are compatible with the standard Ed25519 implementations.
+type Ed25519Sha512SeedCryptoEngine struct {
+	seed io.Reader
+}
+
+// NewEd25519Sha512SeedCryptoEngine creates an engine that reads key material, salts and IVs from seed.
+// if seed is nil - use crypto/rand instead
+func NewEd25519Sha512SeedCryptoEngine(seed io.Reader) *Ed25519Sha512SeedCryptoEngine {
+	return &Ed25519Sha512SeedCryptoEngine{seed}
+}
+
+// CreateDsaSigner implemented interface CryptoEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) CreateDsaSigner(keyPair *KeyPair) DsaSigner {
+	return &Ed25519DsaSigner{keyPair, VerifyLegacy, ed25519Sha512Schema}
+}
+
+// CreateKeyGenerator implemented interface CryptoEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) CreateKeyGenerator() KeyGenerator {
+	generator := NewEd25519KeyGenerator(ref.seed)
+	generator.schema = ed25519Sha512Schema
+	return generator
+}
+
+// CreateBlockCipher implemented interface CryptoEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) CreateBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair) BlockCipher {
+	blockCipher := NewEd25519BlockCipher(senderKeyPair, recipientKeyPair, ref.seed)
+	blockCipher.schema = ed25519Sha512Schema
+	return blockCipher
+}
+
+// CreateKeyAnalyzer implemented interface CryptoEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) CreateKeyAnalyzer() KeyAnalyzer {
+	return NewEd25519KeyAnalyzer()
+}
+
+// Seed implemented interface SeededEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) Seed() io.Reader {
+	return ref.seed
+}
+
+// GetCurve implemented interface CryptoEngine method
+func (ref *Ed25519Sha512SeedCryptoEngine) GetCurve() Curve {
+	return NewEd25519Curve()
+}
+
+// CreateBatchVerifier creates a batch verifier for signatures of this engine that accepts entries by policy
+func (ref *Ed25519Sha512SeedCryptoEngine) CreateBatchVerifier(policy VerificationPolicy) *Ed25519BatchVerifier {
+	batch := NewEd25519BatchVerifier(policy)
+	batch.schema = ed25519Sha512Schema
+	return batch
+}
+
+// Ed25519KeccakSeedCryptoEngine wraps a cryptographic engine ed25519 of NEM NIS1 (legacy Keccak-512)
+// and seed for this engine.
+// Its keys, signatures and encrypted messages are compatible with NIS1 accounts.
+type Ed25519KeccakSeedCryptoEngine struct {
+	seed io.Reader
+}
+
+// NewEd25519KeccakSeedCryptoEngine creates an engine that reads key material, salts and IVs from seed.
+// if seed is nil - use crypto/rand instead
+func NewEd25519KeccakSeedCryptoEngine(seed io.Reader) *Ed25519KeccakSeedCryptoEngine {
+	return &Ed25519KeccakSeedCryptoEngine{seed}
+}
+
+// CreateDsaSigner implemented interface CryptoEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) CreateDsaSigner(keyPair *KeyPair) DsaSigner {
+	return &Ed25519DsaSigner{keyPair, VerifyLegacy, ed25519KeccakSchema}
+}
+
+// CreateKeyGenerator implemented interface CryptoEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) CreateKeyGenerator() KeyGenerator {
+	generator := NewEd25519KeyGenerator(ref.seed)
+	generator.schema = ed25519KeccakSchema
+	return generator
+}
+
+// CreateBlockCipher implemented interface CryptoEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) CreateBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair) BlockCipher {
+	blockCipher := NewEd25519BlockCipher(senderKeyPair, recipientKeyPair, ref.seed)
+	blockCipher.schema = ed25519KeccakSchema
+	return blockCipher
+}
+
+// CreateKeyAnalyzer implemented interface CryptoEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) CreateKeyAnalyzer() KeyAnalyzer {
+	return NewEd25519KeyAnalyzer()
+}
+
+// Seed implemented interface SeededEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) Seed() io.Reader {
+	return ref.seed
+}
+
+// GetCurve implemented interface CryptoEngine method
+func (ref *Ed25519KeccakSeedCryptoEngine) GetCurve() Curve {
+	return NewEd25519Curve()
+}
+
+// CreateBatchVerifier creates a batch verifier for signatures of this engine that accepts entries by policy
+func (ref *Ed25519KeccakSeedCryptoEngine) CreateBatchVerifier(policy VerificationPolicy) *Ed25519BatchVerifier {
+	batch := NewEd25519BatchVerifier(policy)
+	batch.schema = ed25519KeccakSchema
+	return batch
+}
+
 // Ed25519BlockCipher Implementation of the block cipher for Ed25519.
 type Ed25519BlockCipher struct {
+	// Mode selects the format Encrypt writes and the formats Decrypt accepts.
+	Mode CipherMode
+	// KeyDerivation selects the key derivation of the AEAD and stream formats, see DeriveSharedKey.
+	// Encrypt in CipherModeLegacy only accepts KeyDerivationLegacy.
+	KeyDerivation KeyDerivation
+	// Suite selects the AEAD of the AEAD, stream, envelope and sealed box formats.
+	// Encrypt in CipherModeLegacy only accepts CipherSuiteAes256Gcm.
+	Suite            CipherSuite
 	senderKeyPair    *KeyPair
 	recipientKeyPair *KeyPair
 	keyLength        int
 	seed             io.Reader
+	schema           *ed25519Schema
 }
 
 // NewEd25519BlockCipher return Ed25519BlockCipher
@@ -53,10 +191,14 @@ func NewEd25519BlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair, se
 	}
 
 	ref := Ed25519BlockCipher{
+		CipherModeLegacy,
+		KeyDerivationLegacy,
+		CipherSuiteAes256Gcm,
 		senderKeyPair,
 		recipientKeyPair,
 		len(recipientKeyPair.PublicKey.Raw),
 		seed,
+		ed25519Sha3Schema,
 	}
 	return &ref
 }
@@ -101,23 +243,48 @@ func (ref *Ed25519BlockCipher) decode(ciphertext []byte, sharedKey []byte, ivDat
 	bufferSize := len(buf)
 	paddingSize := int(buf[bufferSize-1] & 0xFF)
 
-	if paddingSize == 0 || paddingSize > c.BlockSize() {
-		return nil, errors.New("blocks are corrupted, paddingSize is wrong")
+	// All padding failures return the same error and every padding byte is checked,
+	// so a caller cannot tell which check failed.
+	good := subtle.ConstantTimeLessOrEq(1, paddingSize) & subtle.ConstantTimeLessOrEq(paddingSize, c.BlockSize())
+	for i := 1; i <= c.BlockSize(); i++ {
+		inPadding := subtle.ConstantTimeLessOrEq(i, paddingSize)
+		equal := subtle.ConstantTimeByteEq(buf[bufferSize-i], uint8(paddingSize))
+		good &= subtle.ConstantTimeSelect(inPadding, equal, 1)
+	}
+	if good != 1 {
+		return nil, ErrDecryptionFailed
 	}
 
-	messageSize := bufferSize - paddingSize
+	return buf[:bufferSize-paddingSize], nil
+}
 
-	for i := messageSize; i < bufferSize; i++ {
-		if int(buf[i]) != paddingSize {
-			return nil, errors.New("blocks are corrupted, fake byte is not equal to paddingSize")
-		}
+// GetSharedKey create shared bytes: hash of the ECDH shared secret XOR salt.
+// It is the legacy derivation, salt must have the size of a public key.
+func (ref *Ed25519BlockCipher) GetSharedKey(privateKey *PrivateKey, publicKey *PublicKey, salt []byte) ([]byte, error) {
+
+	if len(salt) < ref.keyLength {
+		return nil, ErrInvalidSaltSize
+	}
+	sharedKey, err := ref.sharedSecret(privateKey, publicKey)
+	if err != nil {
+		return nil, err
 	}
 
-	return buf[:messageSize], nil
+	return ref.saltSharedSecret(sharedKey, salt)
 }
 
-// GetSharedKey create shared bytes
-func (ref *Ed25519BlockCipher) GetSharedKey(privateKey *PrivateKey, publicKey *PublicKey, salt []byte) ([]byte, error) {
+// saltSharedSecret derives the shared key of GetSharedKey from the ECDH shared secret, secret is overwritten.
+func (ref *Ed25519BlockCipher) saltSharedSecret(secret []byte, salt []byte) ([]byte, error) {
+
+	for i := 0; i < ref.keyLength; i++ {
+		secret[i] ^= salt[i]
+	}
+
+	return ref.schema.sharedKeyHash(secret)
+}
+
+// sharedSecret returns the encoding of the ECDH point a * publicKey, where a is the clamped private key scalar.
+func (ref *Ed25519BlockCipher) sharedSecret(privateKey *PrivateKey, publicKey *PublicKey) ([]byte, error) {
 
 	grA, err := NewEd25519EncodedGroupElement(publicKey.Raw)
 	if err != nil {
@@ -128,7 +295,7 @@ func (ref *Ed25519BlockCipher) GetSharedKey(privateKey *PrivateKey, publicKey *P
 		return nil, err
 	}
 	senderA.PrecomputeForScalarMultiplication()
-	el, err := senderA.scalarMultiply(PrepareForScalarMultiply(privateKey))
+	el, err := senderA.scalarMultiply(ref.schema.prepareForScalarMultiply(privateKey))
 	if err != nil {
 		return nil, err
 	}
@@ -136,15 +303,30 @@ func (ref *Ed25519BlockCipher) GetSharedKey(privateKey *PrivateKey, publicKey *P
 	if err != nil {
 		return nil, err
 	}
-	for i := 0; i < ref.keyLength; i++ {
-		sharedKey.Raw[i] ^= salt[i]
-	}
 
-	return HashesSha3_256(sharedKey.Raw)
+	return sharedKey.Raw, nil
 }
 
-// Encrypt slice byte
+// Encrypt slice byte in the format selected by Mode
 func (ref *Ed25519BlockCipher) Encrypt(input []byte) ([]byte, error) {
+
+	if ref.Mode == CipherModeAead {
+		return ref.EncryptWithAssociatedData(input, nil)
+	}
+	// the legacy format records neither the suite nor the key derivation,
+	// others must not silently fall back to AES-256-CBC and GetSharedKey
+	if ref.Suite != CipherSuiteAes256Gcm {
+		return nil, ErrLegacyCipherSuite
+	}
+	if ref.KeyDerivation != KeyDerivationLegacy {
+		return nil, ErrLegacyKeyDerivation
+	}
+
+	return ref.encryptLegacy(input)
+}
+
+// encryptLegacy encrypts with AES-256-CBC: salt | IV | ciphertext.
+func (ref *Ed25519BlockCipher) encryptLegacy(input []byte) ([]byte, error) {
 	// Setup salt.
 	salt := make([]byte, ref.keyLength)
 	_, err := io.ReadFull(ref.seed, salt)
@@ -174,11 +356,36 @@ func (ref *Ed25519BlockCipher) Encrypt(input []byte) ([]byte, error) {
 	return result, nil
 }
 
-// Decrypt slice byte
+// Decrypt slice byte, the format of input is detected.
+// Under CipherModeAead the legacy format is rejected with ErrLegacyCiphertext.
 func (ref *Ed25519BlockCipher) Decrypt(input []byte) ([]byte, error) {
+
+	if ref.Mode == CipherModeAead {
+		return ref.DecryptWithAssociatedData(input, nil)
+	}
+	if isAeadCiphertext(input) {
+		plaintext, err := ref.DecryptWithAssociatedData(input, nil)
+		// a legacy ciphertext has a random salt, which starts with "XPX", a known version and a known suite
+		// with a probability below 2^-37, so a legacy ciphertext that fails as AEAD is tried as legacy
+		if err != nil && isLegacyCiphertextSize(input) {
+			if legacy, legacyErr := ref.decryptLegacy(input); legacyErr == nil {
+				return legacy, nil
+			}
+		}
+		return plaintext, err
+	}
+
+	return ref.decryptLegacy(input)
+}
+
+// decryptLegacy decrypts salt | IV | AES-256-CBC ciphertext.
+func (ref *Ed25519BlockCipher) decryptLegacy(input []byte) ([]byte, error) {
 	if len(input) < 64 {
 		return nil, errors.New("input is to short for decryption")
 	}
+	if !isLegacyCiphertextSize(input) {
+		return nil, ErrDecryptionFailed
+	}
 
 	salt := input[:ref.keyLength]
 	ivData := input[ref.keyLength:48]
@@ -195,29 +402,51 @@ func (ref *Ed25519BlockCipher) Decrypt(input []byte) ([]byte, error) {
 // Ed25519DsaSigner implement DsaSigned interface with Ed25519 algo
 type Ed25519DsaSigner struct {
 	KeyPair *KeyPair
+	// Policy selects the rules Verify accepts signatures by.
+	Policy VerificationPolicy
+	schema *ed25519Schema
 }
 
 // NewEd25519DsaSigner creates a Ed25519 DSA signer.
 func NewEd25519DsaSigner(keyPair *KeyPair) *Ed25519DsaSigner {
-	return &Ed25519DsaSigner{keyPair}
+	return &Ed25519DsaSigner{keyPair, VerifyLegacy, ed25519Sha3Schema}
 }
 
 // Sign message
 func (ref *Ed25519DsaSigner) Sign(mess []byte) (*Signature, error) {
 
+	return ref.sign(ref.messageHash(nil, mess))
+}
+
+// messageHash returns H(dom, inputs..., message) of the message being signed or verified.
+type messageHash func(inputs ...[]byte) ([]byte, error)
+
+// messageHash creates the messageHash of mess, which is held in memory.
+func (ref *Ed25519DsaSigner) messageHash(dom []byte, mess []byte) messageHash {
+
+	return func(inputs ...[]byte) ([]byte, error) {
+		all := make([][]byte, 0, len(inputs)+2)
+		all = append(all, dom)
+		all = append(all, inputs...)
+		return ref.schema.hash(append(all, mess)...)
+	}
+}
+
+// sign creates the signature of the message hashed by hashMessage.
+func (ref *Ed25519DsaSigner) sign(hashMessage messageHash) (*Signature, error) {
+
 	if !ref.KeyPair.HasPrivateKey() {
 		return nil, errors.New("cannot sign without private key")
 	}
 
 	// Hash the private key to improve randomness.
-	hash, err := HashesSha3_512(ref.KeyPair.PrivateKey.Raw)
+	hash, err := ref.schema.hashPrivateKey(ref.KeyPair.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
-	// r = H(hash_b,...,hash_2b-1, data) where b=256.
-	hashR, err := HashesSha3_512(
-		hash[32:], // only include the last 32 bytes of the private key hash
-		mess)
+	// r = H(dom, hash_b,...,hash_2b-1, data) where b=256.
+	hashR, err := hashMessage(
+		hash[32:]) // only include the last 32 bytes of the private key hash
 	if err != nil {
 		return nil, err
 	}
@@ -236,13 +465,12 @@ func (ref *Ed25519DsaSigner) Sign(mess []byte) (*Signature, error) {
 	if err != nil {
 		return nil, err
 	}
-	// S = (r + H(encodedR, encodedA, data) * a) mod group order where
+	// S = (r + H(dom, encodedR, encodedA, data) * a) mod group order where
 	// encodedR and encodedA are the little endian encodings of the group element R and the public key A and
 	// a is the lower 32 bytes of hash after clamping.
-	hashH, err := HashesSha3_512(
+	hashH, err := hashMessage(
 		encodedR.Raw,
-		ref.KeyPair.PublicKey.Raw,
-		mess)
+		ref.KeyPair.PublicKey.Raw)
 	if err != nil {
 		return nil, err
 	}
@@ -251,7 +479,7 @@ func (ref *Ed25519DsaSigner) Sign(mess []byte) (*Signature, error) {
 		return nil, err
 	}
 	hModQ := h.modQ()
-	encodedS := hModQ.multiplyAndAddModQ(PrepareForScalarMultiply(ref.KeyPair.PrivateKey),
+	encodedS := hModQ.multiplyAndAddModQ(ref.schema.prepareForScalarMultiply(ref.KeyPair.PrivateKey),
 		rModQ)
 	// Signature is (encodedR, encodedS)
 	signature, err := NewSignature(encodedR.Raw, encodedS.Raw)
@@ -265,41 +493,149 @@ func (ref *Ed25519DsaSigner) Sign(mess []byte) (*Signature, error) {
 	return signature, nil
 }
 
-// Verify reports whether sig is a valid signature of message 'data' by publicKey. It
-// prevent  panic inside ed25519.Verify
-func (ref *Ed25519DsaSigner) Verify(mess []byte, signature *Signature) (res bool) {
+// Verify reports whether sig is a valid signature of message 'data' by publicKey
+// under the verification policy of the signer.
+func (ref *Ed25519DsaSigner) Verify(mess []byte, signature *Signature) bool {
+
+	return ref.VerifyWithError(mess, signature) == nil
+}
+
+// VerifyWithError works like Verify, but tells why the signature was rejected:
+// ErrInvalidPublicKey, ErrMalleableSignature, ErrInvalidSignatureR, ErrSmallOrderSignatureR,
+// ErrSignatureHash or ErrInvalidSignature. It returns nil if the signature is valid.
+func (ref *Ed25519DsaSigner) VerifyWithError(mess []byte, signature *Signature) error {
+
+	return ref.verify(ref.messageHash(nil, mess), signature)
+}
+
+// verify checks the signature of the message hashed by hashMessage.
+func (ref *Ed25519DsaSigner) verify(hashMessage messageHash, signature *Signature) error {
+
+	switch ref.Policy {
+	case VerifyLegacy:
+		return ref.verifyLegacy(hashMessage, signature)
+	case VerifyStrict, VerifyZip215:
+		A, R, h, err := ref.prepareVerification(hashMessage, signature)
+		if err != nil {
+			return err
+		}
+		one := Ed25519FieldZeroShort()
+		one[0] = 1
+		// 8 * (S * B - h * A - R) = 0
+		sum, err := multiScalarMultiplyVariableTime(
+			[]*Ed25519GroupElement{Ed25519Group.BASE_POINT(), A, MathUtils.NegateGroupElement(R)},
+			[]*Ed25519EncodedFieldElement{
+				{Ed25519FieldZeroShort(), signature.S},
+				negateModQ(h),
+				{Ed25519FieldZeroShort(), one},
+			})
+		if err != nil || !sum.multiplyByCofactor().isNeutral() {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	return ErrUnknownVerificationPolicy
+}
+
+// prepareVerification decodes A and R and computes h = H(dom, encodedR, encodedA, data) mod group order.
+// It returns an error if the signature must be rejected by the policy before the group equation is checked.
+func (ref *Ed25519DsaSigner) prepareVerification(hashMessage messageHash, signature *Signature) (A, R *Ed25519GroupElement, h *Ed25519EncodedFieldElement, err error) {
+
+	encodedA := &Ed25519EncodedGroupElement{ref.KeyPair.PublicKey.Raw}
+	encodedR := &Ed25519EncodedGroupElement{signature.R}
+	switch ref.Policy {
+	case VerifyLegacy:
+		if !isCanonicalScalar(signature.S) {
+			return nil, nil, nil, ErrMalleableSignature
+		}
+		if !ref.IsCanonicalSignature(signature) {
+			return nil, nil, nil, ErrInvalidSignature
+		}
+		if isEqualConstantTime(encodedA.Raw, make([]byte, 32)) {
+			return nil, nil, nil, ErrInvalidPublicKey
+		}
+	case VerifyStrict:
+		if !isCanonicalScalar(signature.S) {
+			return nil, nil, nil, ErrMalleableSignature
+		}
+		if !encodedA.IsCanonical() {
+			return nil, nil, nil, ErrInvalidPublicKey
+		}
+		if !encodedR.IsCanonical() {
+			return nil, nil, nil, ErrInvalidSignatureR
+		}
+	case VerifyZip215:
+		if !isCanonicalScalar(signature.S) {
+			return nil, nil, nil, ErrMalleableSignature
+		}
+	default:
+		return nil, nil, nil, ErrUnknownVerificationPolicy
+	}
+
+	A, err = encodedA.Decode()
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPublicKey
+	}
+	R, err = encodedR.Decode()
+	if err != nil {
+		return nil, nil, nil, ErrInvalidSignatureR
+	}
+	if ref.Policy == VerifyStrict {
+		if A.hasSmallOrder() {
+			return nil, nil, nil, ErrInvalidPublicKey
+		}
+		if R.hasSmallOrder() {
+			return nil, nil, nil, ErrSmallOrderSignatureR
+		}
+	}
+
+	hash, err := hashMessage(encodedR.Raw, encodedA.Raw)
+	if err != nil {
+		return nil, nil, nil, ErrSignatureHash
+	}
+	h, err = NewEd25519EncodedFieldElement(hash)
+	if err != nil {
+		return nil, nil, nil, ErrSignatureHash
+	}
+
+	return A, R, h.modQ(), nil
+}
+
+// verifyLegacy checks that the encoding of S * B - h * A is equal to R.
+func (ref *Ed25519DsaSigner) verifyLegacy(hashMessage messageHash, signature *Signature) error {
+
+	if !isCanonicalScalar(signature.S) {
+		return ErrMalleableSignature
+	}
 
 	if !ref.IsCanonicalSignature(signature) {
-		return false
+		return ErrInvalidSignature
 	}
 
 	if isEqualConstantTime(ref.KeyPair.PublicKey.Raw, make([]byte, 32)) {
-		return false
+		return ErrInvalidPublicKey
 	}
 
-	// h = H(encodedR, encodedA, data).
+	// h = H(dom, encodedR, encodedA, data).
 	rawEncodedR := signature.R
 	rawEncodedA := ref.KeyPair.PublicKey.Raw
-	hashR, err := HashesSha3_512(
+	hashR, err := hashMessage(
 		rawEncodedR,
-		rawEncodedA,
-		mess)
+		rawEncodedA)
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return ErrSignatureHash
 	}
 	h, err := NewEd25519EncodedFieldElement(hashR)
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return ErrSignatureHash
 	}
 	// hReduced = h mod group order
 	hModQ := h.modQ()
 	// Must compute A.
 	A, err := (&Ed25519EncodedGroupElement{rawEncodedA}).Decode()
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return ErrInvalidPublicKey
 	}
 	A.PrecomputeForDoubleScalarMultiplication()
 	// R = encodedS * B - H(encodedR, encodedA, data) * A
@@ -308,27 +644,30 @@ func (ref *Ed25519DsaSigner) Verify(mess []byte, signature *Signature) (res bool
 		hModQ,
 		&Ed25519EncodedFieldElement{Ed25519FieldZeroShort(), signature.S})
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return ErrInvalidSignature
 	}
 	// Compare calculated R to given R.
 	encodedCalculatedR, err := calculatedR.Encode()
 	if err != nil {
-		fmt.Println(err)
-		return false
+		return ErrInvalidSignature
 	}
 
-	return isEqualConstantTime(encodedCalculatedR.Raw, rawEncodedR)
+	if !isEqualConstantTime(encodedCalculatedR.Raw, rawEncodedR) {
+		return ErrInvalidSignature
+	}
+
+	return nil
 }
 
-// IsCanonicalSignature check signature on canonical
+// IsCanonicalSignature check signature on canonical: 0 < S < group order,
+// where S is compared in full width and in constant time
 func (ref *Ed25519DsaSigner) IsCanonicalSignature(signature *Signature) bool {
 
-	sgnS := signature.GetS().Uint64()
-	return sgnS != Ed25519Group.GROUP_ORDER.Uint64() && sgnS > 0
+	return isCanonicalScalar(signature.S) && !isEqualConstantTime(signature.S, Ed25519FieldZeroShort())
 }
 
-// MakeSignatureCanonical return canonical signature
+// MakeSignatureCanonical return canonical signature: S is reduced modulo the group order.
+// The returned signature does not share memory with signature.
 func (ref *Ed25519DsaSigner) MakeSignatureCanonical(signature *Signature) (*Signature, error) {
 
 	sign := make([]byte, 64)
@@ -337,13 +676,23 @@ func (ref *Ed25519DsaSigner) MakeSignatureCanonical(signature *Signature) (*Sign
 	if err != nil {
 		return nil, err
 	}
-	sModQ := s.modQ()
-	return NewSignature(signature.R, sModQ.Raw)
+	r := make([]byte, len(signature.R))
+	copy(r, signature.R)
+	canonical, err := NewSignature(r, s.modQ().Raw)
+	if err != nil {
+		return nil, err
+	}
+	if !ref.IsCanonicalSignature(canonical) {
+		return nil, errors.New("signature with S = 0 mod group order cannot be made canonical")
+	}
+
+	return canonical, nil
 }
 
 // Ed25519KeyGenerator Implementation of the key generator for Ed25519.
 type Ed25519KeyGenerator struct {
-	seed io.Reader
+	seed   io.Reader
+	schema *ed25519Schema
 }
 
 // NewEd25519KeyGenerator return new Ed25519KeyGenerator
@@ -352,7 +701,7 @@ func NewEd25519KeyGenerator(seed io.Reader) *Ed25519KeyGenerator {
 		seed = rand.Reader
 	}
 
-	ref := Ed25519KeyGenerator{seed}
+	ref := Ed25519KeyGenerator{seed, ed25519Sha3Schema}
 	return &ref
 }
 
@@ -373,7 +722,7 @@ func (ref *Ed25519KeyGenerator) GenerateKeyPair() (*KeyPair, error) {
 // DerivePublicKey return public key based on Ed25519Group.BASE_POINT
 func (ref *Ed25519KeyGenerator) DerivePublicKey(privateKey *PrivateKey) *PublicKey {
 
-	a := PrepareForScalarMultiply(privateKey)
+	a := ref.schema.prepareForScalarMultiply(privateKey)
 	// a * base point is the public key.
 	pubKey, err := Ed25519Group.BASE_POINT().scalarMultiply(a)
 	if err != nil {
@@ -2,10 +2,15 @@ package crypto
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/proximax-storage/go-xpx-utils"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
 )
 
 const (
@@ -106,3 +111,267 @@ func Test_EncryptAndDecrypt(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, message, string(decryptedData))
 }
+
+func TestEd25519Sha512SeedCryptoEngine_SignMatchesRFC8032(t *testing.T) {
+	// RFC 8032, section 7.1, TEST 3
+	privateKey, err := NewPrivateKeyfromHexString("c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7")
+	assert.Nil(t, err)
+	engine := CryptoEngines.Ed25519Sha512Engine
+	kp, err := NewKeyPair(privateKey, engine.CreateKeyGenerator().DerivePublicKey(privateKey), engine)
+	assert.Nil(t, err)
+	assert.Equal(t, "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025", kp.PublicKey.hex())
+
+	signature, err := engine.CreateDsaSigner(kp).Sign([]byte{0xaf, 0x82})
+	assert.Nil(t, err)
+	assert.Equal(t, "6291d657deec24024827e69c3abe01a30ce548a284743a445e3680d7db5ac3ac"+
+		"18ff9b538d16f290ae67f760984dc6594a7c15e9716ed28dc027beceea1ec40a", signature.String())
+	assert.True(t, engine.CreateDsaSigner(kp).Verify([]byte{0xaf, 0x82}, signature))
+}
+
+func TestEd25519Sha512SeedCryptoEngine_InteroperatesWithStandardEd25519(t *testing.T) {
+	engine := CryptoEngines.Ed25519Sha512Engine
+	mess := []byte(message)
+	for i := 0; i < 10; i++ {
+		kp, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+
+		signature, err := engine.CreateDsaSigner(kp).Sign(mess)
+		assert.Nil(t, err)
+		assert.True(t, ed25519.Verify(kp.PublicKey.Raw, mess, signature.Bytes()))
+
+		standardKey := ed25519.NewKeyFromSeed(kp.PrivateKey.Raw)
+		assert.Equal(t, []byte(standardKey.Public().(ed25519.PublicKey)), kp.PublicKey.Raw)
+		standardSignature, err := NewSignatureFromBytes(ed25519.Sign(standardKey, mess))
+		assert.Nil(t, err)
+		assert.True(t, engine.CreateDsaSigner(kp).Verify(mess, standardSignature))
+		// the SHA3 engine must not accept RFC 8032 signatures
+		assert.False(t, CryptoEngines.Ed25519Engine.CreateDsaSigner(kp).Verify(mess, standardSignature))
+	}
+}
+
+func TestEd25519KeccakSeedCryptoEngine_DerivesNIS1PublicKey(t *testing.T) {
+	// NIS1 test-keys vector
+	privateKey, err := NewPrivateKeyfromHexString("575dbb3062267eff57c970a336ebbc8fbcfe12c5bd3ed7bc11eb0481d7704ced")
+	assert.Nil(t, err)
+
+	publicKey := CryptoEngines.Ed25519KeccakEngine.CreateKeyGenerator().DerivePublicKey(privateKey)
+
+	assert.Equal(t, "c5f54ba980fcbb657dbaaa42700539b207873e134d2375efeab5f1ab52f87844", publicKey.hex())
+}
+
+func TestEd25519KeccakSeedCryptoEngine_SignMatchesNIS1(t *testing.T) {
+	// NIS1 test-sign vector
+	privateKey, err := NewPrivateKeyfromHexString("abf4cf55a2b3f742d7543d9cc17f50447b969e6e06f5ea9195d428ab12b7318d")
+	assert.Nil(t, err)
+	engine := CryptoEngines.Ed25519KeccakEngine
+	kp, err := NewKeyPair(privateKey, engine.CreateKeyGenerator().DerivePublicKey(privateKey), engine)
+	assert.Nil(t, err)
+	data, err := hex.DecodeString("8ce03cd60514233b86789729102ea09e867fc6d964dea8c2018ef7d0a2e0e24bf7e348e917116690b9")
+	assert.Nil(t, err)
+
+	signature, err := engine.CreateDsaSigner(kp).Sign(data)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "8a558c728c21c126181e5e654b404a45b4f0137ce88177435a69978cc6bec1f4", kp.PublicKey.hex())
+	assert.Equal(t, "d9cec0cc0e3465fab229f8e1d6db68ab9cc99a18cb0435f70deb6100948576cd"+
+		"5c0aa1feb550bdd8693ef81eb10a556a622db1f9301986827b96716a7134230c", signature.String())
+	assert.True(t, engine.CreateDsaSigner(kp).Verify(data, signature))
+	assert.False(t, CryptoEngines.Ed25519Engine.CreateDsaSigner(kp).Verify(data, signature))
+}
+
+func TestEd25519KeccakSeedCryptoEngine_EncryptAndDecrypt(t *testing.T) {
+	engine := CryptoEngines.Ed25519KeccakEngine
+	sender, err := NewKeyPairByEngine(engine)
+	assert.Nil(t, err)
+	recipient, err := NewKeyPairByEngine(engine)
+	assert.Nil(t, err)
+
+	encryptedData, err := engine.CreateBlockCipher(sender, recipient).Encrypt([]byte(message))
+	assert.Nil(t, err)
+	decryptedData, err := engine.CreateBlockCipher(sender, recipient).Decrypt(encryptedData)
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(decryptedData))
+
+	// the shared key must be derived with Keccak-256
+	blockCipher := engine.CreateBlockCipher(sender, recipient).(*Ed25519BlockCipher)
+	sharedKey, err := blockCipher.GetSharedKey(sender.PrivateKey, recipient.PublicKey, make([]byte, 32))
+	assert.Nil(t, err)
+	sharedKeySha3, err := NewEd25519BlockCipher(sender, recipient, nil).GetSharedKey(sender.PrivateKey, recipient.PublicKey, make([]byte, 32))
+	assert.Nil(t, err)
+	assert.NotEqual(t, sharedKeySha3, sharedKey)
+}
+
+type verificationVector struct {
+	Description string `json:"description"`
+	PublicKey   string `json:"publicKey"`
+	Message     string `json:"message"`
+	Signature   string `json:"signature"`
+	Legacy      bool   `json:"legacy"`
+	Strict      bool   `json:"strict"`
+	Zip215      bool   `json:"zip215"`
+}
+
+func (ref *verificationVector) expected(policy VerificationPolicy) bool {
+	switch policy {
+	case VerifyStrict:
+		return ref.Strict
+	case VerifyZip215:
+		return ref.Zip215
+	}
+	return ref.Legacy
+}
+
+// loadVerificationVectors reads the SHA3 vectors for the verification policies,
+// they supplement the published SHA-512 vectors with the edge cases of the SHA3 schema.
+func loadVerificationVectors(t *testing.T) []*verificationVector {
+
+	return loadVerificationVectorsFile(t, "testdata/ed25519_sha3_verification_vectors.json")
+}
+
+// loadVerificationVectorsFile reads a suite of verification vectors from path.
+func loadVerificationVectorsFile(t *testing.T, path string) []*verificationVector {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := make([]*verificationVector, 0)
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	return vectors
+}
+
+func (ref *verificationVector) decode(t *testing.T) (*PublicKey, []byte, *Signature) {
+	publicKey, err := NewPublicKeyfromHex(ref.PublicKey)
+	assert.Nil(t, err)
+	mess, err := hex.DecodeString(ref.Message)
+	assert.Nil(t, err)
+	rawSignature, err := hex.DecodeString(ref.Signature)
+	assert.Nil(t, err)
+	signature, err := NewSignatureFromBytes(rawSignature)
+	assert.Nil(t, err)
+
+	return publicKey, mess, signature
+}
+
+func TestEd25519DsaSigner_VerificationPolicyVectors(t *testing.T) {
+	for _, vector := range loadVerificationVectors(t) {
+		publicKey, mess, signature := vector.decode(t)
+		for _, policy := range []VerificationPolicy{VerifyLegacy, VerifyStrict, VerifyZip215} {
+			signer := NewEd25519DsaSigner(&KeyPair{nil, publicKey})
+			signer.Policy = policy
+
+			assert.Equalf(t, vector.expected(policy), signer.Verify(mess, signature), "%s (policy %d)", vector.Description, policy)
+		}
+	}
+}
+
+// The RFC 8032 vectors are TEST 1 to 3 of section 7.1 and the first lines of SUPERCOP sign.input,
+// they are valid under every policy. The ZIP-215 vectors are the small order cases of
+// "It's 255:19AM" by Henry de Valence, valid only under VerifyZip215: their S is zero,
+// which the legacy policy rejects, and their A and R have small order, which the strict policy rejects.
+func TestEd25519DsaSigner_PublishedSha512Vectors(t *testing.T) {
+	for _, path := range []string{
+		"testdata/ed25519_sha512_rfc8032_vectors.json",
+		"testdata/ed25519_sha512_zip215_vectors.json",
+	} {
+		for _, vector := range loadVerificationVectorsFile(t, path) {
+			publicKey, mess, signature := vector.decode(t)
+			for _, policy := range []VerificationPolicy{VerifyLegacy, VerifyStrict, VerifyZip215} {
+				signer := CryptoEngines.Ed25519Sha512Engine.CreateDsaSigner(&KeyPair{nil, publicKey}).(*Ed25519DsaSigner)
+				signer.Policy = policy
+
+				assert.Equalf(t, vector.expected(policy), signer.Verify(mess, signature), "%s (policy %d)", vector.Description, policy)
+			}
+		}
+	}
+}
+
+func TestEd25519DsaSigner_UnknownPolicyRejects(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	signer := NewEd25519DsaSigner(kp)
+	signature, err := signer.Sign([]byte(message))
+	assert.Nil(t, err)
+
+	signer.Policy = VerificationPolicy(42)
+
+	assert.False(t, signer.Verify([]byte(message), signature))
+	assert.Equal(t, ErrUnknownVerificationPolicy, signer.VerifyWithError([]byte(message), signature))
+}
+
+func TestEd25519DsaSigner_IsCanonicalSignatureComparesFullWidth(t *testing.T) {
+	signer := NewEd25519DsaSigner(keyPair)
+	order := Ed25519Group.GROUP_ORDER
+	cases := []struct {
+		s         *big.Int
+		canonical bool
+	}{
+		{big.NewInt(0), false},
+		{big.NewInt(1), true},
+		{(&big.Int{}).Sub(order, big.NewInt(1)), true},
+		{order, false},
+		{(&big.Int{}).Add(order, big.NewInt(1)), false},
+		// the low 64 bits differ from the group order, but S is still greater
+		{(&big.Int{}).Add(order, (&big.Int{}).Lsh(big.NewInt(1), 64)), false},
+		{(&big.Int{}).Lsh(big.NewInt(1), 253), false},
+		{(&big.Int{}).Sub((&big.Int{}).Lsh(big.NewInt(1), 256), big.NewInt(1)), false},
+	}
+	for _, c := range cases {
+		signature, err := NewSignature(make([]byte, 32), utils.BigIntToByteArray(c.s, 32))
+		assert.Nil(t, err)
+
+		assert.Equalf(t, c.canonical, signer.IsCanonicalSignature(signature), "S = %s", c.s)
+	}
+}
+
+func TestEd25519DsaSigner_VerifyWithErrorRejectsMalleatedSignature(t *testing.T) {
+	signer := NewEd25519DsaSigner(keyPair)
+	signature, err := signer.Sign([]byte(message))
+	assert.Nil(t, err)
+	assert.Nil(t, signer.VerifyWithError([]byte(message), signature))
+
+	// S + L verifies against the same group equation
+	malleatedS := (&big.Int{}).Add(signature.GetS(), Ed25519Group.GROUP_ORDER)
+	malleated, err := NewSignature(signature.R, utils.BigIntToByteArray(malleatedS, 32))
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrMalleableSignature, signer.VerifyWithError([]byte(message), malleated))
+	assert.False(t, signer.Verify([]byte(message), malleated))
+	assert.Equal(t, ErrInvalidSignature, signer.VerifyWithError([]byte("other message"), signature))
+
+	canonical, err := signer.MakeSignatureCanonical(malleated)
+	assert.Nil(t, err)
+	assert.Equal(t, signature, canonical)
+}
+
+func TestEd25519DsaSigner_VerifyWithErrorTellsRejectionReason(t *testing.T) {
+	reasons := map[string]error{
+		"valid signature":          nil,
+		"wrong message":            ErrInvalidSignature,
+		"malleated S + L":          ErrMalleableSignature,
+		"small order A":            ErrInvalidPublicKey,
+		"non-canonical identity A": ErrInvalidPublicKey,
+		"non-canonical identity R": ErrInvalidSignatureR,
+		"small order R":            ErrSmallOrderSignatureR,
+	}
+	for _, vector := range loadVerificationVectors(t) {
+		reason, ok := reasons[vector.Description]
+		if !ok {
+			continue
+		}
+		publicKey, mess, signature := vector.decode(t)
+		signer := &Ed25519DsaSigner{&KeyPair{nil, publicKey}, VerifyStrict, ed25519Sha3Schema}
+
+		assert.Equalf(t, reason, signer.VerifyWithError(mess, signature), vector.Description)
+	}
+}
+
+func TestEd25519DsaSigner_VerifyWithErrorRejectsZeroPublicKeyUnderLegacyPolicy(t *testing.T) {
+	signature, err := NewEd25519DsaSigner(keyPair).Sign([]byte(message))
+	assert.Nil(t, err)
+	signer := NewEd25519DsaSigner(&KeyPair{nil, NewPublicKey(make([]byte, 32))})
+
+	assert.Equal(t, ErrInvalidPublicKey, signer.VerifyWithError([]byte(message), signature))
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"io"
+)
+
+// The sealed box format encrypts a message to a recipient without a sender identity:
+//
+//	"XPXB" | version | suite | ephemeral public key | nonce | ciphertext and tag
+//
+// A new ephemeral key pair is generated for every message. The key of the AEAD of the suite is derived
+// with HKDF-SHA256 from the ECDH shared secret of the ephemeral key and the recipient key,
+// the salt is ephemeral public key | recipient public key and the info is "xpx-crypto sealed box" | suite. The header is the associated data.
+// sealedBoxFormatVersion is the version of the sealed box format.
+const sealedBoxFormatVersion = 1
+
+var (
+	// sealedBoxMagic starts every sealed box.
+	sealedBoxMagic = []byte("XPXB")
+	// sealedBoxPrefixSize is the size of "XPXB" | version | suite | ephemeral public key.
+	sealedBoxPrefixSize = len(sealedBoxMagic) + 2 + 32
+	// sealedBoxKeyInfo starts the HKDF info of the sealed box key, the suite follows.
+	sealedBoxKeyInfo = []byte("xpx-crypto sealed box")
+)
+
+// EncryptAnonymous encrypts input for the recipient of the block cipher with an ephemeral sender key,
+// the sender of the block cipher is not used and may be nil. It does not depend on Mode.
+func (ref *Ed25519BlockCipher) EncryptAnonymous(input []byte) ([]byte, error) {
+
+	if !ref.Suite.isKnown() {
+		return nil, ErrUnknownCipherSuite
+	}
+	ephemeralKey := make([]byte, 32)
+	_, err := io.ReadFull(ref.seed, ephemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := NewPrivateKey(ephemeralKey)
+	publicKey := (&Ed25519KeyGenerator{ref.seed, ref.schema}).DerivePublicKey(privateKey)
+
+	recipient := ref.recipientKeyPair.PublicKey
+	aead, err := ref.newAead(privateKey, recipient, sealedBoxSalt(publicKey, recipient), ref.Suite.keyInfo(sealedBoxKeyInfo), ref.Suite)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, sealedBoxPrefixSize+aead.NonceSize()+len(input)+aead.Overhead())
+	header = append(header, sealedBoxMagic...)
+	header = append(header, sealedBoxFormatVersion, byte(ref.Suite))
+	header = append(header, publicKey.Raw...)
+	header = append(header, make([]byte, aead.NonceSize())...)
+	nonce := header[sealedBoxPrefixSize:]
+	_, err = io.ReadFull(ref.seed, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(header, nonce, input, header), nil
+}
+
+// DecryptAnonymous decrypts a sealed box with the recipient private key of the block cipher,
+// the sender of the block cipher is not used and may be nil.
+// The cipher suite is read from the sealed box, Suite is not used.
+func (ref *Ed25519BlockCipher) DecryptAnonymous(input []byte) ([]byte, error) {
+
+	if len(input) < len(sealedBoxMagic)+2 || !bytes.Equal(input[:len(sealedBoxMagic)], sealedBoxMagic) ||
+		input[len(sealedBoxMagic)] != sealedBoxFormatVersion || !CipherSuite(input[len(sealedBoxMagic)+1]).isKnown() {
+		return nil, ErrUnsupportedCipherFormat
+	}
+	suite := CipherSuite(input[len(sealedBoxMagic)+1])
+	headerSize := sealedBoxPrefixSize + suite.nonceSize()
+	if len(input) < headerSize {
+		return nil, ErrDecryptionFailed
+	}
+
+	publicKey := NewPublicKey(input[len(sealedBoxMagic)+2 : sealedBoxPrefixSize])
+	recipient := ref.recipientKeyPair
+	aead, err := ref.newAead(recipient.PrivateKey, publicKey, sealedBoxSalt(publicKey, recipient.PublicKey), suite.keyInfo(sealedBoxKeyInfo), suite)
+	// the ephemeral public key is part of the ciphertext
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	if len(input) < headerSize+aead.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+
+	header := input[:headerSize]
+	plaintext, err := aead.Open(nil, header[sealedBoxPrefixSize:], input[headerSize:], header)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// sealedBoxSalt binds the key of a sealed box to both public keys.
+func sealedBoxSalt(ephemeral *PublicKey, recipient *PublicKey) []byte {
+
+	return aeadAssociatedData(ephemeral.Raw, recipient.Raw)
+}
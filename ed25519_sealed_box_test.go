@@ -0,0 +1,125 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEd25519BlockCipher_AnonymousRoundTrip(t *testing.T) {
+	var _ AnonymousBlockCipher = (*Ed25519BlockCipher)(nil)
+
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		recipient, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+		// the encrypting side knows only the recipient public key
+		publicRecipient := &KeyPair{nil, recipient.PublicKey}
+
+		encrypted, err := NewBlockCipher(nil, publicRecipient, engine).(AnonymousBlockCipher).EncryptAnonymous([]byte(message))
+		assert.Nil(t, err)
+		assert.Len(t, encrypted, sealedBoxPrefixSize+12+len(message)+16)
+
+		decrypted, err := NewBlockCipher(nil, recipient, engine).(AnonymousBlockCipher).DecryptAnonymous(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(decrypted))
+	}
+}
+
+func TestEd25519BlockCipher_AnonymousCipherSuites(t *testing.T) {
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+
+	for _, suite := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+		encrypter := NewEd25519BlockCipher(nil, &KeyPair{nil, recipient.PublicKey}, nil)
+		encrypter.Suite = suite
+		encrypted, err := encrypter.EncryptAnonymous([]byte(message))
+		assert.Nil(t, err)
+		assert.Equal(t, byte(suite), encrypted[len(sealedBoxMagic)+1])
+		assert.Len(t, encrypted, sealedBoxPrefixSize+suite.nonceSize()+len(message)+16)
+
+		// the suite is read from the sealed box
+		decrypter := NewEd25519BlockCipher(nil, recipient, nil)
+		decrypted, err := decrypter.DecryptAnonymous(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, message, string(decrypted))
+
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(sealedBoxMagic)+1] = byte(suite%3 + 1)
+		_, err = decrypter.DecryptAnonymous(tampered)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+
+	encrypter := NewEd25519BlockCipher(nil, recipient, nil)
+	encrypter.Suite = CipherSuite(0)
+	_, err = encrypter.EncryptAnonymous([]byte(message))
+	assert.Equal(t, ErrUnknownCipherSuite, err)
+}
+
+func TestEd25519BlockCipher_AnonymousUsesEphemeralKeys(t *testing.T) {
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	blockCipher := NewEd25519BlockCipher(nil, recipient, nil)
+
+	first, err := blockCipher.EncryptAnonymous([]byte(message))
+	assert.Nil(t, err)
+	second, err := blockCipher.EncryptAnonymous([]byte(message))
+	assert.Nil(t, err)
+
+	ephemeralKey := first[len(sealedBoxMagic)+2 : len(sealedBoxMagic)+2+32]
+	assert.NotEqual(t, ephemeralKey, second[len(sealedBoxMagic)+2:len(sealedBoxMagic)+2+32])
+	assert.NotEqual(t, recipient.PublicKey.Raw, ephemeralKey)
+}
+
+func TestEd25519BlockCipher_AnonymousRejectsOthers(t *testing.T) {
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	outsider, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	blockCipher := NewEd25519BlockCipher(nil, recipient, nil)
+	encrypted, err := blockCipher.EncryptAnonymous([]byte(message))
+	assert.Nil(t, err)
+
+	_, err = NewEd25519BlockCipher(nil, outsider, nil).DecryptAnonymous(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	for i := len(sealedBoxMagic) + 2; i < len(encrypted); i++ {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+
+		_, err = blockCipher.DecryptAnonymous(tampered)
+		assert.Equalf(t, ErrDecryptionFailed, err, "byte %d", i)
+	}
+	for _, i := range []int{0, len(sealedBoxMagic), len(sealedBoxMagic) + 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] = 0xff
+
+		_, err = blockCipher.DecryptAnonymous(tampered)
+		assert.Equal(t, ErrUnsupportedCipherFormat, err)
+	}
+	_, err = blockCipher.DecryptAnonymous(encrypted[:sealedBoxPrefixSize+12+15])
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = blockCipher.DecryptAnonymous(encrypted[:sealedBoxPrefixSize+12-1])
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestEd25519BlockCipher_AnonymousRejectsSmallOrderKeys(t *testing.T) {
+	recipient, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	smallOrder, err := hex.DecodeString("0100000000000000000000000000000000000000000000000000000000000000")
+	assert.Nil(t, err)
+
+	_, err = NewEd25519BlockCipher(nil, &KeyPair{nil, NewPublicKey(smallOrder)}, nil).EncryptAnonymous([]byte(message))
+	assert.Equal(t, ErrInvalidSharedSecret, err)
+
+	blockCipher := NewEd25519BlockCipher(nil, recipient, nil)
+	encrypted, err := blockCipher.EncryptAnonymous([]byte(message))
+	assert.Nil(t, err)
+	copy(encrypted[len(sealedBoxMagic)+2:], smallOrder)
+	_, err = blockCipher.DecryptAnonymous(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
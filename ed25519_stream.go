@@ -0,0 +1,148 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ErrMessageChanged is returned by SignReader when the two reads of the message differ.
+var ErrMessageChanged = errors.New("message changed between the reads of the reader")
+
+// Ed25519StreamSigner signs and verifies a message that is written to it in chunks.
+// It implements hash.Hash: the message is hashed by the prehash function of the signer
+// and signed with Ed25519ph, so the message is never kept in memory.
+// Signatures are interchangeable with Ed25519DsaSigner.SignPrehashed and Ed25519DsaSigner.VerifyPrehashed.
+type Ed25519StreamSigner struct {
+	signer  *Ed25519DsaSigner
+	context []byte
+	prehash hash.Hash
+}
+
+// NewEd25519StreamSigner creates a stream signer around signer.
+// context may be empty and must not be longer than 255 bytes.
+func NewEd25519StreamSigner(signer *Ed25519DsaSigner, context []byte) (*Ed25519StreamSigner, error) {
+
+	if len(context) > maxContextSize {
+		return nil, ErrContextTooLong
+	}
+
+	return &Ed25519StreamSigner{signer, context, signer.NewPrehash()}, nil
+}
+
+// Write adds more data to the message. It never returns an error.
+func (ref *Ed25519StreamSigner) Write(p []byte) (int, error) {
+
+	return ref.prehash.Write(p)
+}
+
+// Sum appends the prehash of the message written so far to b.
+// It does not change the underlying state.
+func (ref *Ed25519StreamSigner) Sum(b []byte) []byte {
+
+	return ref.prehash.Sum(b)
+}
+
+// Reset starts a new message.
+func (ref *Ed25519StreamSigner) Reset() {
+
+	ref.prehash.Reset()
+}
+
+// Size returns the size of the prehash of the message.
+func (ref *Ed25519StreamSigner) Size() int {
+
+	return ref.prehash.Size()
+}
+
+// BlockSize returns the block size of the prehash function.
+func (ref *Ed25519StreamSigner) BlockSize() int {
+
+	return ref.prehash.BlockSize()
+}
+
+// Sign signs the message written so far.
+// It does not change the underlying state, so more data can be written and signed again.
+func (ref *Ed25519StreamSigner) Sign() (*Signature, error) {
+
+	return ref.signer.SignPrehashed(ref.Sum(nil), ref.context)
+}
+
+// Verify checks the signature of the message written so far.
+// It returns nil if the signature is valid and the reason of the rejection otherwise.
+func (ref *Ed25519StreamSigner) Verify(signature *Signature) error {
+
+	return ref.signer.VerifyPrehashed(ref.Sum(nil), ref.context, signature)
+}
+
+// SignReader signs the message read from r with pure Ed25519, the signature is equal to Sign of the whole message.
+// Pure Ed25519 hashes the message twice, so r is read to the end, rewound to the position it had
+// when SignReader was called and read to the end again.
+// Both reads are digested with SHA3-256 and ErrMessageChanged is returned if they differ:
+// the nonce of the first read must never sign another message, which would reveal the private key.
+func (ref *Ed25519DsaSigner) SignReader(r io.ReadSeeker) (*Signature, error) {
+
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	var digest []byte
+	return ref.sign(func(inputs ...[]byte) ([]byte, error) {
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		read := sha3.New256()
+		hashed, err := ref.hashReader(inputs, io.TeeReader(r, read))
+		if err != nil {
+			return nil, err
+		}
+		if digest == nil {
+			digest = read.Sum(nil)
+		} else if !bytes.Equal(digest, read.Sum(nil)) {
+			return nil, ErrMessageChanged
+		}
+
+		return hashed, nil
+	})
+}
+
+// VerifyReader checks the pure Ed25519 signature of the message read from r.
+// Verification hashes the message once, so r is read to the end only once.
+// It returns nil if the signature is valid and the reason of the rejection otherwise.
+func (ref *Ed25519DsaSigner) VerifyReader(r io.Reader, signature *Signature) error {
+
+	read := false
+	return ref.verify(func(inputs ...[]byte) ([]byte, error) {
+		if read {
+			return nil, ErrSignatureHash
+		}
+		read = true
+
+		return ref.hashReader(inputs, r)
+	}, signature)
+}
+
+// hashReader returns H(inputs..., message read from r).
+func (ref *Ed25519DsaSigner) hashReader(inputs [][]byte, r io.Reader) ([]byte, error) {
+
+	hash := ref.schema.newHash()
+	for _, b := range inputs {
+		if _, err := hash.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.Copy(hash, r); err != nil {
+		return nil, err
+	}
+
+	return hash.Sum(nil), nil
+}
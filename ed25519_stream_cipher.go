@@ -0,0 +1,378 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// The stream format encrypts data that does not fit in memory with the STREAM construction:
+//
+//	"XPXS" | version | suite | salt | nonce prefix | chunk 0 | chunk 1 | ... | last chunk
+//
+// Version 2 has the key derivation byte of KeyDerivation after the suite, version 1 is KeyDerivationLegacy.
+// Every chunk is StreamChunkSize bytes of plaintext encrypted with the AEAD of the suite, the last chunk may be shorter.
+// The nonce of a chunk is nonce prefix | chunk counter(4) | last chunk flag(1), so reordered,
+// dropped and truncated chunks fail authentication. The nonce prefix has 7 bytes, 19 with XChaCha20-Poly1305.
+// With KeyDerivationLegacy the key is derived with HKDF-SHA256 from the ECDH shared secret and
+// the info is "xpx-crypto stream" | suite. The other key derivations use their hash and append
+// the info of DeriveSharedKey, which binds the key to the public keys of the sender and the recipient.
+// The header is the associated data of every chunk.
+const (
+	// StreamChunkSize is the size of the plaintext of a chunk.
+	StreamChunkSize = 64 * 1024
+	// streamFormatVersion is the version of the stream format.
+	streamFormatVersion = 1
+	// streamFormatVersionKdf is the version of the stream format with a key derivation byte after the suite.
+	streamFormatVersionKdf = 2
+	// streamNonceSuffixSize is the size of chunk counter | last chunk flag.
+	streamNonceSuffixSize = 5
+	// streamLastChunk flags the nonce of the last chunk.
+	streamLastChunk = 1
+)
+
+var (
+	// streamMagic starts every encrypted stream.
+	streamMagic = []byte("XPXS")
+	// streamKeyInfo starts the HKDF info of the stream key, the suite follows.
+	streamKeyInfo = []byte("xpx-crypto stream")
+)
+
+var (
+	// ErrStreamClosed is returned when data is written to a closed StreamEncrypter.
+	ErrStreamClosed = errors.New("encrypted stream is closed")
+	// ErrStreamTooLong is returned when a stream has more chunks than the chunk counter can number.
+	ErrStreamTooLong = errors.New("encrypted stream is too long")
+)
+
+// StreamEncrypter encrypts the data written to it in chunks and writes them to the underlying writer.
+// It keeps one chunk in memory. Close must be called to write the last chunk.
+type StreamEncrypter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint64
+	plain   []byte
+	sealed  []byte
+	err     error
+}
+
+// NewStreamEncrypter writes the stream header to dst and returns the writer
+// that encrypts the data from the sender to the recipient of the block cipher with the AEAD of Suite
+// and the key derivation of KeyDerivation.
+func (ref *Ed25519BlockCipher) NewStreamEncrypter(dst io.Writer) (*StreamEncrypter, error) {
+
+	if !ref.Suite.isKnown() {
+		return nil, ErrUnknownCipherSuite
+	}
+	if ref.KeyDerivation > KeyDerivationHkdfSha3_256 {
+		return nil, ErrUnknownKeyDerivation
+	}
+	header := make([]byte, streamHeaderSize(ref.Suite, ref.KeyDerivation))
+	copy(header, streamMagic)
+	header[len(streamMagic)] = streamFormatVersion
+	header[len(streamMagic)+1] = byte(ref.Suite)
+	if ref.KeyDerivation != KeyDerivationLegacy {
+		header[len(streamMagic)] = streamFormatVersionKdf
+		header[len(streamMagic)+2] = byte(ref.KeyDerivation)
+	}
+	_, err := io.ReadFull(ref.seed, header[streamPrefixSize(ref.KeyDerivation):])
+	if err != nil {
+		return nil, err
+	}
+
+	salt := streamSalt(header, ref.KeyDerivation)
+	aead, err := ref.newStreamAead(ref.senderKeyPair.PrivateKey, ref.recipientKeyPair.PublicKey, salt, ref.Suite, ref.KeyDerivation)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dst.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamEncrypter{
+		dst:    dst,
+		aead:   aead,
+		header: header,
+		nonce:  streamNonce(header, ref.KeyDerivation, aead.NonceSize()),
+		plain:  make([]byte, 0, StreamChunkSize),
+		sealed: make([]byte, 0, StreamChunkSize+aead.Overhead()),
+	}, nil
+}
+
+// Write encrypts p. A chunk is written to the underlying writer once it is full
+// and more data follows, so a stream never ends with a full chunk that is not flagged as the last one.
+func (ref *StreamEncrypter) Write(p []byte) (int, error) {
+
+	if ref.err != nil {
+		return 0, ref.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(ref.plain) == StreamChunkSize {
+			err := ref.flush(false)
+			if err != nil {
+				return written, err
+			}
+		}
+		n := copy(ref.plain[len(ref.plain):StreamChunkSize], p)
+		ref.plain = ref.plain[:len(ref.plain)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (ref *StreamEncrypter) Close() error {
+
+	if ref.err == ErrStreamClosed {
+		return nil
+	}
+	if ref.err != nil {
+		return ref.err
+	}
+
+	err := ref.flush(true)
+	if err != nil {
+		return err
+	}
+	ref.err = ErrStreamClosed
+
+	return nil
+}
+
+func (ref *StreamEncrypter) flush(last bool) error {
+
+	err := setStreamNonce(ref.nonce, ref.counter, last)
+	if err != nil {
+		ref.err = err
+		return err
+	}
+	ref.sealed = ref.aead.Seal(ref.sealed[:0], ref.nonce, ref.plain, ref.header)
+	_, err = ref.dst.Write(ref.sealed)
+	if err != nil {
+		ref.err = err
+		return err
+	}
+	ref.counter++
+	ref.plain = ref.plain[:0]
+
+	return nil
+}
+
+// StreamDecrypter reads an encrypted stream from the underlying reader and returns the decrypted data.
+// It keeps one chunk in memory and returns only authenticated data. A stream that is truncated,
+// reordered or modified returns ErrDecryptionFailed.
+type StreamDecrypter struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint64
+	sealed  []byte
+	carry   int
+	plain   []byte
+	unread  []byte
+	err     error
+}
+
+// NewStreamDecrypter reads the stream header from src and returns the reader
+// that decrypts the data from the sender to the recipient of the block cipher.
+// The cipher suite and the key derivation are read from the header, Suite and KeyDerivation are not used.
+func (ref *Ed25519BlockCipher) NewStreamDecrypter(src io.Reader) (*StreamDecrypter, error) {
+
+	version := make([]byte, len(streamMagic)+2)
+	err := readStreamHeader(src, version)
+	if err != nil {
+		return nil, err
+	}
+	suite := CipherSuite(version[len(streamMagic)+1])
+	if !bytes.Equal(version[:len(streamMagic)], streamMagic) || !suite.isKnown() {
+		return nil, ErrUnsupportedCipherFormat
+	}
+	keyDerivation := KeyDerivationLegacy
+	switch version[len(streamMagic)] {
+	case streamFormatVersion:
+	case streamFormatVersionKdf:
+		kdf := make([]byte, 1)
+		err = readStreamHeader(src, kdf)
+		if err != nil {
+			return nil, err
+		}
+		keyDerivation = KeyDerivation(kdf[0])
+		if keyDerivation == KeyDerivationLegacy || keyDerivation > KeyDerivationHkdfSha3_256 {
+			return nil, ErrUnsupportedCipherFormat
+		}
+	default:
+		return nil, ErrUnsupportedCipherFormat
+	}
+	header := make([]byte, streamHeaderSize(suite, keyDerivation))
+	copy(header, version)
+	if keyDerivation != KeyDerivationLegacy {
+		header[len(version)] = byte(keyDerivation)
+	}
+	err = readStreamHeader(src, header[streamPrefixSize(keyDerivation):])
+	if err != nil {
+		return nil, err
+	}
+
+	salt := streamSalt(header, keyDerivation)
+	aead, err := ref.newStreamAead(ref.recipientKeyPair.PrivateKey, ref.senderKeyPair.PublicKey, salt, suite, keyDerivation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamDecrypter{
+		src:    src,
+		aead:   aead,
+		header: header,
+		nonce:  streamNonce(header, keyDerivation, aead.NonceSize()),
+		// one more byte tells whether a full chunk is the last one
+		sealed: make([]byte, StreamChunkSize+aead.Overhead()+1),
+		plain:  make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// Read reads the decrypted data. It returns io.EOF after the last chunk.
+func (ref *StreamDecrypter) Read(p []byte) (int, error) {
+
+	for len(ref.unread) == 0 {
+		if ref.err != nil {
+			return 0, ref.err
+		}
+		ref.err = ref.readChunk()
+	}
+
+	n := copy(p, ref.unread)
+	ref.unread = ref.unread[n:]
+
+	return n, nil
+}
+
+// readChunk decrypts the next chunk. It returns io.EOF after the last chunk.
+func (ref *StreamDecrypter) readChunk() error {
+
+	chunkSize := StreamChunkSize + ref.aead.Overhead()
+	n, err := io.ReadFull(ref.src, ref.sealed[ref.carry:])
+	n += ref.carry
+	last := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	if !last {
+		n = chunkSize
+	}
+
+	err = setStreamNonce(ref.nonce, ref.counter, last)
+	if err != nil {
+		return err
+	}
+	plain, err := ref.aead.Open(ref.plain[:0], ref.nonce, ref.sealed[:n], ref.header)
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+	ref.counter++
+	ref.unread = plain
+
+	if last {
+		// Read returns io.EOF once the data of the last chunk has been read
+		return io.EOF
+	}
+	ref.sealed[0] = ref.sealed[chunkSize]
+	ref.carry = 1
+
+	return nil
+}
+
+// newStreamAead creates the AEAD of suite with the stream key derived by keyDerivation.
+func (ref *Ed25519BlockCipher) newStreamAead(privateKey *PrivateKey, publicKey *PublicKey, salt []byte, suite CipherSuite, keyDerivation KeyDerivation) (cipher.AEAD, error) {
+
+	info := suite.keyInfo(streamKeyInfo)
+	if keyDerivation == KeyDerivationLegacy {
+		return ref.newAead(privateKey, publicKey, salt, info, suite)
+	}
+
+	newHash, err := keyDerivation.newHash()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ref.hkdfSharedKey(privateKey, publicKey, newHash, salt, append(info, ref.keyDerivationInfo(keyDerivation)...))
+	if err != nil {
+		return nil, err
+	}
+
+	return suite.newAead(key)
+}
+
+// readStreamHeader reads a part of the stream header, a short stream is ErrDecryptionFailed.
+func readStreamHeader(src io.Reader, b []byte) error {
+
+	_, err := io.ReadFull(src, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrDecryptionFailed
+	}
+
+	return err
+}
+
+// streamPrefixSize returns the size of "XPXS" | version | suite, with the key derivation byte of version 2.
+func streamPrefixSize(keyDerivation KeyDerivation) int {
+
+	if keyDerivation == KeyDerivationLegacy {
+		return len(streamMagic) + 2
+	}
+
+	return len(streamMagic) + 3
+}
+
+// streamHeaderSize returns the size of the prefix | salt | nonce prefix of a stream header.
+func streamHeaderSize(suite CipherSuite, keyDerivation KeyDerivation) int {
+
+	return streamPrefixSize(keyDerivation) + aeadSaltSize + suite.nonceSize() - streamNonceSuffixSize
+}
+
+// streamSalt returns the HKDF salt of a stream header.
+func streamSalt(header []byte, keyDerivation KeyDerivation) []byte {
+
+	start := streamPrefixSize(keyDerivation)
+	return header[start : start+aeadSaltSize]
+}
+
+// streamNonce returns the chunk nonce with the nonce prefix of a stream header.
+func streamNonce(header []byte, keyDerivation KeyDerivation, nonceSize int) []byte {
+
+	nonce := make([]byte, nonceSize)
+	copy(nonce, header[streamPrefixSize(keyDerivation)+aeadSaltSize:])
+	return nonce
+}
+
+// setStreamNonce sets the chunk counter and the last chunk flag of nonce.
+func setStreamNonce(nonce []byte, counter uint64, last bool) error {
+
+	if counter > math.MaxUint32 {
+		return ErrStreamTooLong
+	}
+	binary.BigEndian.PutUint32(nonce[len(nonce)-streamNonceSuffixSize:], uint32(counter))
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = streamLastChunk
+	}
+
+	return nil
+}
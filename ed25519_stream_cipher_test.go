@@ -0,0 +1,204 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encryptStream(t *testing.T, blockCipher *Ed25519BlockCipher, input []byte) []byte {
+	var buf bytes.Buffer
+	encrypter, err := blockCipher.NewStreamEncrypter(&buf)
+	assert.Nil(t, err)
+
+	// odd writes cross the chunk boundaries
+	for len(input) > 0 {
+		n := 1000
+		if n > len(input) {
+			n = len(input)
+		}
+		written, err := encrypter.Write(input[:n])
+		assert.Nil(t, err)
+		assert.Equal(t, n, written)
+		input = input[n:]
+	}
+	assert.Nil(t, encrypter.Close())
+
+	return buf.Bytes()
+}
+
+func decryptStream(blockCipher *Ed25519BlockCipher, input []byte) ([]byte, error) {
+	decrypter, err := blockCipher.NewStreamDecrypter(iotest.HalfReader(bytes.NewReader(input)))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(decrypter)
+}
+
+func TestStreamCipher_RoundTrip(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 100} {
+		input := make([]byte, size)
+		_, err := rand.Read(input)
+		assert.Nil(t, err)
+
+		encrypted := encryptStream(t, encrypter, input)
+		chunks := size/StreamChunkSize + 1
+		if size > 0 && size%StreamChunkSize == 0 {
+			chunks--
+		}
+		assert.Len(t, encrypted, streamHeaderSize(CipherSuiteAes256Gcm, KeyDerivationLegacy)+size+chunks*16)
+
+		decrypted, err := decryptStream(decrypter, encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, input, append([]byte{}, decrypted...))
+	}
+}
+
+func TestStreamCipher_DetectsTruncationAndReordering(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	input := make([]byte, 3*StreamChunkSize+100)
+	_, err := rand.Read(input)
+	assert.Nil(t, err)
+	encrypted := encryptStream(t, encrypter, input)
+	chunkSize := StreamChunkSize + 16
+	headerSize := streamHeaderSize(CipherSuiteAes256Gcm, KeyDerivationLegacy)
+
+	// a stream cut at a chunk boundary misses the last chunk flag
+	for _, size := range []int{headerSize, headerSize + chunkSize, headerSize + 3*chunkSize, len(encrypted) - 1} {
+		_, err = decryptStream(decrypter, encrypted[:size])
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+
+	reordered := append([]byte{}, encrypted[:headerSize]...)
+	reordered = append(reordered, encrypted[headerSize+chunkSize:headerSize+2*chunkSize]...)
+	reordered = append(reordered, encrypted[headerSize:headerSize+chunkSize]...)
+	reordered = append(reordered, encrypted[headerSize+2*chunkSize:]...)
+	_, err = decryptStream(decrypter, reordered)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	_, err = decryptStream(decrypter, append(append([]byte{}, encrypted...), 0))
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	for _, i := range []int{len(streamMagic) + 2, headerSize - 1, headerSize + chunkSize, len(encrypted) - 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+		_, err = decryptStream(decrypter, tampered)
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+}
+
+func TestStreamCipher_ReturnsOnlyAuthenticatedData(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	input := make([]byte, 2*StreamChunkSize)
+	encrypted := encryptStream(t, encrypter, input)
+	encrypted[len(encrypted)-1] ^= 0x01
+
+	stream, err := decrypter.NewStreamDecrypter(bytes.NewReader(encrypted))
+	assert.Nil(t, err)
+	read, err := io.ReadFull(stream, make([]byte, len(input)))
+
+	assert.Equal(t, StreamChunkSize, read)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestStreamCipher_Errors(t *testing.T) {
+	encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	encrypted := encryptStream(t, encrypter, []byte(message))
+
+	_, err := decrypter.NewStreamDecrypter(bytes.NewReader(encrypted[:3]))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = decrypter.NewStreamDecrypter(bytes.NewReader(encrypted[:streamHeaderSize(CipherSuiteAes256Gcm, KeyDerivationLegacy)-1]))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	for _, i := range []int{0, len(streamMagic), len(streamMagic) + 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] = 0xff
+		_, err = decrypter.NewStreamDecrypter(bytes.NewReader(tampered))
+		assert.Equal(t, ErrUnsupportedCipherFormat, err)
+	}
+
+	var buf bytes.Buffer
+	stream, err := encrypter.NewStreamEncrypter(&buf)
+	assert.Nil(t, err)
+	assert.Nil(t, stream.Close())
+	assert.Nil(t, stream.Close())
+	_, err = stream.Write([]byte(message))
+	assert.Equal(t, ErrStreamClosed, err)
+
+	// the stream of one key pair cannot be decrypted with another
+	_, other := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	_, err = decryptStream(other, encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestStreamCipher_CipherSuites(t *testing.T) {
+	input := make([]byte, 2*StreamChunkSize+1)
+	_, err := rand.Read(input)
+	assert.Nil(t, err)
+
+	for _, suite := range []CipherSuite{CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+		encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+		encrypter.Suite = suite
+
+		encrypted := encryptStream(t, encrypter, input)
+		assert.Equal(t, byte(suite), encrypted[len(streamMagic)+1])
+		assert.Len(t, encrypted, streamHeaderSize(suite, KeyDerivationLegacy)+len(input)+3*16)
+
+		// the suite is read from the header
+		decrypted, err := decryptStream(decrypter, encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, input, decrypted)
+
+		_, err = decryptStream(decrypter, encrypted[:len(encrypted)-StreamChunkSize])
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+}
+
+func TestStreamCipher_KeyDerivations(t *testing.T) {
+	input := make([]byte, StreamChunkSize+1)
+	_, err := rand.Read(input)
+	assert.Nil(t, err)
+
+	for _, keyDerivation := range []KeyDerivation{KeyDerivationHkdfSha256, KeyDerivationHkdfSha3_256} {
+		encrypter, decrypter := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+		encrypter.KeyDerivation = keyDerivation
+
+		encrypted := encryptStream(t, encrypter, input)
+		assert.Equal(t, byte(streamFormatVersionKdf), encrypted[len(streamMagic)])
+		assert.Equal(t, byte(keyDerivation), encrypted[len(streamMagic)+2])
+		assert.Len(t, encrypted, streamHeaderSize(CipherSuiteAes256Gcm, keyDerivation)+len(input)+2*16)
+
+		// the key derivation is read from the header
+		decrypted, err := decryptStream(decrypter, encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, input, decrypted)
+
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(streamMagic)+2] = byte(KeyDerivationHkdfSha256 + KeyDerivationHkdfSha3_256 - keyDerivation)
+		_, err = decryptStream(decrypter, tampered)
+		assert.Equal(t, ErrDecryptionFailed, err)
+		for _, unknown := range []KeyDerivation{KeyDerivationLegacy, KeyDerivationHkdfSha3_256 + 1} {
+			tampered[len(streamMagic)+2] = byte(unknown)
+			_, err = decryptStream(decrypter, tampered)
+			assert.Equal(t, ErrUnsupportedCipherFormat, err)
+		}
+		_, err = decryptStream(decrypter, encrypted[:len(streamMagic)+2])
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+
+	encrypter, _ := newAeadBlockCiphers(t, CryptoEngines.Ed25519Engine)
+	encrypter.KeyDerivation = KeyDerivationHkdfSha3_256 + 1
+	_, err = encrypter.NewStreamEncrypter(ioutil.Discard)
+	assert.Equal(t, ErrUnknownKeyDerivation, err)
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func TestEd25519StreamSigner_ImplementsHash(t *testing.T) {
+	stream, err := NewEd25519StreamSigner(NewEd25519DsaSigner(keyPair), nil)
+	assert.Nil(t, err)
+
+	var _ hash.Hash = stream
+	assert.Equal(t, 64, stream.Size())
+}
+
+func TestEd25519StreamSigner_MatchesSignPrehashed(t *testing.T) {
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		kp, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+		signer := engine.CreateDsaSigner(kp).(*Ed25519DsaSigner)
+		stream, err := NewEd25519StreamSigner(signer, []byte("storage"))
+		assert.Nil(t, err)
+		for i := 0; i < 100; i++ {
+			_, err = stream.Write([]byte(message))
+			assert.Nil(t, err)
+		}
+
+		signature, err := stream.Sign()
+		assert.Nil(t, err)
+
+		prehash := signer.NewPrehash()
+		_, err = prehash.Write([]byte(strings.Repeat(message, 100)))
+		assert.Nil(t, err)
+		assert.Nil(t, signer.VerifyPrehashed(prehash.Sum(nil), []byte("storage"), signature))
+		assert.Nil(t, stream.Verify(signature))
+
+		_, err = stream.Write([]byte("tail"))
+		assert.Nil(t, err)
+		assert.Equal(t, ErrInvalidSignature, stream.Verify(signature))
+
+		stream.Reset()
+		_, err = stream.Write([]byte(strings.Repeat(message, 100)))
+		assert.Nil(t, err)
+		assert.Nil(t, stream.Verify(signature))
+	}
+}
+
+func TestEd25519StreamSigner_RejectsLongContext(t *testing.T) {
+	_, err := NewEd25519StreamSigner(NewEd25519DsaSigner(keyPair), []byte(strings.Repeat("c", maxContextSize+1)))
+
+	assert.Equal(t, ErrContextTooLong, err)
+}
+
+func TestEd25519DsaSigner_SignReaderMatchesSign(t *testing.T) {
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		kp, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+		signer := engine.CreateDsaSigner(kp).(*Ed25519DsaSigner)
+		mess := []byte(strings.Repeat(message, 1000))
+		expected, err := signer.Sign(mess)
+		assert.Nil(t, err)
+
+		// the reader is not at its start
+		reader := bytes.NewReader(append([]byte("header"), mess...))
+		_, err = reader.Seek(int64(len("header")), io.SeekStart)
+		assert.Nil(t, err)
+		signature, err := signer.SignReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, signature)
+		assert.Nil(t, signer.VerifyReader(bytes.NewReader(mess), signature))
+		assert.Equal(t, ErrInvalidSignature, signer.VerifyReader(bytes.NewReader(mess[1:]), signature))
+	}
+}
+
+// mutatingReader returns another message after every rewind to its start.
+type mutatingReader struct {
+	messages [][]byte
+	reader   *bytes.Reader
+}
+
+func (ref *mutatingReader) Read(p []byte) (int, error) {
+	return ref.reader.Read(p)
+}
+
+func (ref *mutatingReader) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart && len(ref.messages) > 0 {
+		ref.reader, ref.messages = bytes.NewReader(ref.messages[0]), ref.messages[1:]
+	}
+	return ref.reader.Seek(offset, whence)
+}
+
+func TestEd25519DsaSigner_SignReaderRejectsChangingMessage(t *testing.T) {
+	signer := NewEd25519DsaSigner(keyPair)
+	reader := &mutatingReader{
+		[][]byte{[]byte("message one"), []byte("message two")},
+		bytes.NewReader(nil),
+	}
+
+	signature, err := signer.SignReader(reader)
+
+	assert.Nil(t, signature)
+	assert.Equal(t, ErrMessageChanged, err)
+}
+
+func TestEd25519DsaSigner_VerifyReaderFailsOnReadError(t *testing.T) {
+	signer := NewEd25519DsaSigner(keyPair)
+	signature, err := signer.Sign([]byte(message))
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrSignatureHash, signer.VerifyReader(failingReader{}, signature))
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// HardenedKeyOffset is added to the index of a hardened child key.
+// SLIP-0010 allows only hardened derivation for Ed25519.
+const HardenedKeyOffset uint32 = 0x80000000
+
+const (
+	// minHdSeedSize and maxHdSeedSize bound the master seed as BIP-32 does (128 - 512 bits).
+	minHdSeedSize = 16
+	maxHdSeedSize = 64
+	// extendedKeyVersion is the first byte of a serialized extended key.
+	extendedKeyVersion = 1
+	// extendedKeySize is version(1) | depth(1) | parent fingerprint(4) | child index(4) |
+	// chain code(32) | private key(32) | checksum(4).
+	extendedKeySize = 78
+)
+
+// slip10Ed25519Curve is the HMAC key of the SLIP-0010 master key generation for Ed25519.
+var slip10Ed25519Curve = []byte("ed25519 seed")
+
+var (
+	// ErrInvalidDerivationPath is returned when a derivation path cannot be parsed.
+	ErrInvalidDerivationPath = errors.New("invalid derivation path")
+	// ErrNonHardenedDerivation is returned when a derivation path has a non hardened index.
+	ErrNonHardenedDerivation = errors.New("Ed25519 supports only hardened derivation")
+	// ErrInvalidHdSeedSize is returned when the master seed is shorter than 16 bytes or longer than 64 bytes.
+	ErrInvalidHdSeedSize = errors.New("the length of master seed must be from 16 to 64 bytes")
+	// ErrMaxDerivationDepth is returned when a child of a key at depth 255 is derived.
+	ErrMaxDerivationDepth = errors.New("maximum derivation depth is reached")
+	// ErrInvalidExtendedKey is returned when a serialized extended key is malformed.
+	ErrInvalidExtendedKey = errors.New("invalid extended key")
+)
+
+// DerivationPath is a list of child indexes from the master key, e.g. m/44'/43'/0'/0'/0'.
+type DerivationPath []uint32
+
+// ParseDerivationPath parses a path like m/44'/43'/0'/0'/0'.
+// Every index must be hardened, it is marked by ' or h.
+func ParseDerivationPath(path string) (DerivationPath, error) {
+
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidDerivationPath
+	}
+
+	result := make(DerivationPath, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		if part == "" || part[0] == '+' || part[0] == '-' {
+			return nil, ErrInvalidDerivationPath
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyOffset {
+			return nil, ErrInvalidDerivationPath
+		}
+		if !hardened {
+			return nil, ErrNonHardenedDerivation
+		}
+
+		result = append(result, uint32(index)+HardenedKeyOffset)
+	}
+
+	return result, nil
+}
+
+func (ref DerivationPath) String() string {
+
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range ref {
+		b.WriteString("/")
+		if index >= HardenedKeyOffset {
+			b.WriteString(strconv.FormatUint(uint64(index-HardenedKeyOffset), 10))
+			b.WriteString("'")
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(index), 10))
+		}
+	}
+
+	return b.String()
+}
+
+// ExtendedKey is a private key together with the chain code its children are derived with (SLIP-0010).
+// The private key derivation does not depend on the engine,
+// the engine only derives the public key and the fingerprint.
+type ExtendedKey struct {
+	PrivateKey *PrivateKey
+	ChainCode  []byte
+	// Depth is 0 for the master key.
+	Depth byte
+	// ParentFingerprint is the fingerprint of the parent key, it is 0 for the master key.
+	ParentFingerprint []byte
+	// ChildIndex is the index the key was derived with from its parent, it is 0 for the master key.
+	ChildIndex uint32
+	engine     CryptoEngine
+}
+
+// NewMasterKey creates the master key from seed.
+// if crypto engine is nil - default Engine
+func NewMasterKey(seed []byte, engine CryptoEngine) (*ExtendedKey, error) {
+
+	if len(seed) < minHdSeedSize || len(seed) > maxHdSeedSize {
+		return nil, ErrInvalidHdSeedSize
+	}
+
+	privateKey, chainCode := slip10Hmac(slip10Ed25519Curve, seed)
+
+	return &ExtendedKey{privateKey, chainCode, 0, make([]byte, 4), 0, CryptoEngines.resolve(engine)}, nil
+}
+
+// DeriveKeyPair derives the key pair at path (e.g. m/44'/43'/0'/0'/0') from seed.
+// if crypto engine is nil - default Engine
+func DeriveKeyPair(seed []byte, path string, engine CryptoEngine) (*KeyPair, error) {
+
+	derivationPath, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed, engine)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.Derive(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.KeyPair()
+}
+
+// Child derives the hardened child key with index, index must be not less than HardenedKeyOffset.
+func (ref *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+
+	if index < HardenedKeyOffset {
+		return nil, ErrNonHardenedDerivation
+	}
+	if ref.Depth == 255 {
+		return nil, ErrMaxDerivationDepth
+	}
+
+	// I = HMAC-SHA512(chain code, 0x00 || private key || ser32(index))
+	data := make([]byte, 0, 37)
+	data = append(data, 0)
+	data = append(data, ref.PrivateKey.Raw...)
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], index)
+	privateKey, chainCode := slip10Hmac(ref.ChainCode, data)
+
+	return &ExtendedKey{privateKey, chainCode, ref.Depth + 1, ref.Fingerprint(), index, ref.engine}, nil
+}
+
+// Derive derives the key at path relative to ref.
+func (ref *ExtendedKey) Derive(path DerivationPath) (*ExtendedKey, error) {
+
+	key := ref
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+
+	return key, nil
+}
+
+// PublicKey derives the public key of the extended key.
+func (ref *ExtendedKey) PublicKey() *PublicKey {
+
+	return ref.engine.CreateKeyGenerator().DerivePublicKey(ref.PrivateKey)
+}
+
+// KeyPair returns the key pair of the extended key.
+func (ref *ExtendedKey) KeyPair() (*KeyPair, error) {
+
+	return NewKeyPair(ref.PrivateKey, nil, ref.engine)
+}
+
+// Fingerprint returns the first 4 bytes of RIPEMD-160(SHA-256(0x00 || public key)).
+func (ref *ExtendedKey) Fingerprint() []byte {
+
+	hash, err := HashesSha_256(append([]byte{0}, ref.PublicKey().Raw...))
+	if err != nil {
+		return make([]byte, 4)
+	}
+	hash, err = HashesRipemd160(hash)
+	if err != nil {
+		return make([]byte, 4)
+	}
+
+	return hash[:4]
+}
+
+// Bytes serializes the extended key to 78 bytes:
+// version | depth | parent fingerprint | child index | chain code | private key | checksum,
+// where checksum is the first 4 bytes of SHA3-256 of the preceding bytes.
+// The engine is not serialized.
+func (ref *ExtendedKey) Bytes() []byte {
+
+	b := make([]byte, 0, extendedKeySize)
+	b = append(b, extendedKeyVersion, ref.Depth)
+	b = append(b, ref.ParentFingerprint...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[6:], ref.ChildIndex)
+	b = append(b, ref.ChainCode...)
+	b = append(b, ref.PrivateKey.Raw...)
+
+	return append(b, extendedKeyChecksum(b)...)
+}
+
+func (ref *ExtendedKey) String() string {
+
+	return hex.EncodeToString(ref.Bytes())
+}
+
+// NewExtendedKeyFromBytes deserializes an extended key created by ExtendedKey.Bytes.
+// if crypto engine is nil - default Engine
+func NewExtendedKeyFromBytes(b []byte, engine CryptoEngine) (*ExtendedKey, error) {
+
+	if len(b) != extendedKeySize || b[0] != extendedKeyVersion {
+		return nil, ErrInvalidExtendedKey
+	}
+	if !hmac.Equal(extendedKeyChecksum(b[:74]), b[74:]) {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	raw := make([]byte, len(b))
+	copy(raw, b)
+
+	return &ExtendedKey{
+		NewPrivateKey(raw[42:74]),
+		raw[10:42],
+		raw[1],
+		raw[2:6],
+		binary.BigEndian.Uint32(raw[6:10]),
+		CryptoEngines.resolve(engine),
+	}, nil
+}
+
+// NewExtendedKeyFromHexString deserializes an extended key created by ExtendedKey.String.
+// if crypto engine is nil - default Engine
+func NewExtendedKeyFromHexString(sHex string, engine CryptoEngine) (*ExtendedKey, error) {
+
+	b, err := hex.DecodeString(sHex)
+	if err != nil {
+		return nil, ErrInvalidExtendedKey
+	}
+
+	return NewExtendedKeyFromBytes(b, engine)
+}
+
+// slip10Hmac splits HMAC-SHA512(key, data) into the private key and the chain code.
+func slip10Hmac(key []byte, data []byte) (*PrivateKey, []byte) {
+
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	i := mac.Sum(nil)
+
+	return NewPrivateKey(i[:32]), i[32:]
+}
+
+func extendedKeyChecksum(b []byte) []byte {
+
+	hash, err := HashesSha3_256(b)
+	if err != nil {
+		return nil
+	}
+
+	return hash[:4]
+}
@@ -0,0 +1,155 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath("m/44'/43'/0h/0H/2147483647'")
+
+	assert.Nil(t, err)
+	assert.Equal(t, DerivationPath{
+		44 + HardenedKeyOffset,
+		43 + HardenedKeyOffset,
+		HardenedKeyOffset,
+		HardenedKeyOffset,
+		2147483647 + HardenedKeyOffset,
+	}, path)
+	assert.Equal(t, "m/44'/43'/0'/0'/2147483647'", path.String())
+
+	path, err = ParseDerivationPath("m")
+	assert.Nil(t, err)
+	assert.Empty(t, path)
+}
+
+func TestParseDerivationPath_Rejects(t *testing.T) {
+	for _, path := range []string{"", "44'", "M/44'", "m/", "m//1'", "m/x'", "m/-1'", "m/+1'", "m/2147483648'", "m/1''", "m/ 1'"} {
+		_, err := ParseDerivationPath(path)
+		assert.Equalf(t, ErrInvalidDerivationPath, err, path)
+	}
+
+	_, err := ParseDerivationPath("m/44'/43'/0")
+	assert.Equal(t, ErrNonHardenedDerivation, err)
+}
+
+func TestExtendedKey_DerivesSLIP10Vectors(t *testing.T) {
+	// SLIP-0010 test vectors for ed25519
+	vectors := []struct {
+		seed, path, fingerprint, chainCode, privateKey, publicKey string
+	}{
+		{"000102030405060708090a0b0c0d0e0f", "m", "00000000",
+			"90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+			"2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+			"a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'", "ddebc675",
+			"8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+			"68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+			"8c8a13df77a28f3445213a0f432fde644acaa215fc72dcdf300d5efaa85d350c"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1'", "13dab143",
+			"a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14",
+			"b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+			"1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187"},
+		{"000102030405060708090a0b0c0d0e0f", "m/0'/1'/2'/2'/1000000000'", "d6322ccd",
+			"68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230",
+			"8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793",
+			"3c24da049451555d51a7014a37337aa4e12d41e485abccfa46b47dfb2af54b7a"},
+		{"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+			"m/0'/2147483647'/1'/2147483646'/2'", "422c654b",
+			"5d70af781f3a37b829f0d060924d5e960bdc02e85423494afc0b1a41bbe196d4",
+			"551d333177df541ad876a60ea71f00447931c0a9da16f227c11ea080d7391b8d",
+			"47150c75db263559a70d5778bf36abbab30fb061ad69f69ece61a72b0cfa4fc0"},
+	}
+
+	for _, vector := range vectors {
+		seed, err := hex.DecodeString(vector.seed)
+		assert.Nil(t, err)
+		path, err := ParseDerivationPath(vector.path)
+		assert.Nil(t, err)
+		master, err := NewMasterKey(seed, CryptoEngines.Ed25519Sha512Engine)
+		assert.Nil(t, err)
+
+		key, err := master.Derive(path)
+
+		assert.Nil(t, err)
+		assert.Equal(t, vector.fingerprint, hex.EncodeToString(key.ParentFingerprint), vector.path)
+		assert.Equal(t, vector.chainCode, hex.EncodeToString(key.ChainCode), vector.path)
+		assert.Equal(t, vector.privateKey, key.PrivateKey.String(), vector.path)
+		assert.Equal(t, vector.publicKey, key.PublicKey().hex(), vector.path)
+		assert.Equal(t, byte(len(path)), key.Depth)
+	}
+}
+
+func TestDeriveKeyPair(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	assert.Nil(t, err)
+
+	kp, err := DeriveKeyPair(seed, "m/0'/1'", CryptoEngines.Ed25519Sha512Engine)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", kp.PrivateKey.String())
+	assert.Equal(t, "1932a5270f335bed617d5b935c80aedb1a35bd9fc1e31acafd5372c30f5c1187", kp.PublicKey.hex())
+
+	// the default engine derives the same private key
+	kp, err = DeriveKeyPair(seed, "m/0'/1'", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", kp.PrivateKey.String())
+	signature, err := NewSignerFromKeyPair(kp, nil).Sign([]byte(message))
+	assert.Nil(t, err)
+	assert.True(t, NewSignerFromKeyPair(kp, nil).Verify([]byte(message), signature))
+
+	_, err = DeriveKeyPair(seed, "m/0'/1", nil)
+	assert.Equal(t, ErrNonHardenedDerivation, err)
+}
+
+func TestExtendedKey_Rejects(t *testing.T) {
+	_, err := NewMasterKey(make([]byte, minHdSeedSize-1), nil)
+	assert.Equal(t, ErrInvalidHdSeedSize, err)
+	_, err = NewMasterKey(make([]byte, maxHdSeedSize+1), nil)
+	assert.Equal(t, ErrInvalidHdSeedSize, err)
+
+	master, err := NewMasterKey(make([]byte, minHdSeedSize), nil)
+	assert.Nil(t, err)
+	_, err = master.Child(1)
+	assert.Equal(t, ErrNonHardenedDerivation, err)
+
+	master.Depth = 255
+	_, err = master.Child(HardenedKeyOffset)
+	assert.Equal(t, ErrMaxDerivationDepth, err)
+}
+
+func TestExtendedKey_Serialization(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	assert.Nil(t, err)
+	master, err := NewMasterKey(seed, nil)
+	assert.Nil(t, err)
+	key, err := master.Derive(DerivationPath{HardenedKeyOffset, 1 + HardenedKeyOffset})
+	assert.Nil(t, err)
+
+	serialized := key.String()
+	assert.Len(t, key.Bytes(), extendedKeySize)
+	restored, err := NewExtendedKeyFromHexString(serialized, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, key, restored)
+	child, err := key.Child(HardenedKeyOffset)
+	assert.Nil(t, err)
+	restoredChild, err := restored.Child(HardenedKeyOffset)
+	assert.Nil(t, err)
+	assert.Equal(t, child.String(), restoredChild.String())
+
+	corrupted := key.Bytes()
+	corrupted[50] ^= 1
+	_, err = NewExtendedKeyFromBytes(corrupted, nil)
+	assert.Equal(t, ErrInvalidExtendedKey, err)
+	_, err = NewExtendedKeyFromBytes(corrupted[:extendedKeySize-1], nil)
+	assert.Equal(t, ErrInvalidExtendedKey, err)
+	_, err = NewExtendedKeyFromHexString("zz", nil)
+	assert.Equal(t, ErrInvalidExtendedKey, err)
+}
@@ -0,0 +1,351 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// HpkeMode is the mode of HPKE (RFC 9180).
+type HpkeMode uint8
+
+// HPKE modes, the PSK modes are not supported.
+const (
+	// HpkeModeBase encrypts to the recipient public key, the sender is anonymous.
+	HpkeModeBase HpkeMode = 0x00
+	// HpkeModeAuth encrypts to the recipient public key and authenticates the sender key pair.
+	HpkeModeAuth HpkeMode = 0x02
+)
+
+// HpkeAead is the AEAD identifier of HPKE (RFC 9180).
+type HpkeAead uint16
+
+// HPKE AEADs.
+const (
+	HpkeAeadAes128Gcm        HpkeAead = 0x0001
+	HpkeAeadChaCha20Poly1305 HpkeAead = 0x0003
+)
+
+const (
+	// hpkeKemX25519HkdfSha256 is DHKEM(X25519, HKDF-SHA256).
+	hpkeKemX25519HkdfSha256 = 0x0020
+	// hpkeKdfHkdfSha256 is HKDF-SHA256.
+	hpkeKdfHkdfSha256 = 0x0001
+	// hpkeNonceSize is the nonce size of both AEADs.
+	hpkeNonceSize = 12
+)
+
+var (
+	// hpkeVersionLabel starts every labeled HKDF input.
+	hpkeVersionLabel = []byte("HPKE-v1")
+	// hpkeKemSuiteID is "KEM" | KEM identifier.
+	hpkeKemSuiteID = []byte{'K', 'E', 'M', 0x00, hpkeKemX25519HkdfSha256}
+)
+
+var (
+	// ErrUnknownHpkeMode is returned when the HPKE mode is not supported.
+	ErrUnknownHpkeMode = errors.New("unknown HPKE mode")
+	// ErrUnknownHpkeAead is returned when the HPKE AEAD is not supported.
+	ErrUnknownHpkeAead = errors.New("unknown HPKE AEAD")
+)
+
+// HpkeBlockCipher implements BlockCipher with single-shot HPKE (RFC 9180):
+// DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and AES-128-GCM or ChaCha20-Poly1305.
+// The X25519 keys are converted from the Ed25519 key pairs.
+// A ciphertext is the encapsulated key followed by the AEAD ciphertext, as RFC 9180 sends them.
+type HpkeBlockCipher struct {
+	// Mode is HpkeModeBase or HpkeModeAuth, both sides must use the same mode.
+	Mode HpkeMode
+	// Aead is the AEAD, both sides must use the same AEAD.
+	Aead HpkeAead
+	// Info binds the keys to the application context, both sides must use the same info.
+	Info             []byte
+	senderKeyPair    *KeyPair
+	recipientKeyPair *KeyPair
+	engine           CryptoEngine
+	seed             io.Reader
+}
+
+// NewHpkeBlockCipher creates HpkeBlockCipher in HpkeModeBase with HpkeAeadAes128Gcm.
+// The sender key pair is used only by HpkeModeAuth, its private key is required for encryption.
+// The recipient private key is required for decryption.
+// if crypto engine is nil - default Engine, it converts the private keys.
+// if seed is nil - use crypto/rand instead
+func NewHpkeBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair, engine CryptoEngine, seed io.Reader) *HpkeBlockCipher {
+	if seed == nil {
+		seed = rand.Reader
+	}
+
+	return &HpkeBlockCipher{
+		HpkeModeBase,
+		HpkeAeadAes128Gcm,
+		nil,
+		senderKeyPair,
+		recipientKeyPair,
+		CryptoEngines.resolve(engine),
+		seed,
+	}
+}
+
+// Encrypt slice byte
+func (ref *HpkeBlockCipher) Encrypt(input []byte) ([]byte, error) {
+
+	return ref.EncryptWithAssociatedData(input, nil)
+}
+
+// Decrypt slice byte
+func (ref *HpkeBlockCipher) Decrypt(input []byte) ([]byte, error) {
+
+	return ref.DecryptWithAssociatedData(input, nil)
+}
+
+// EncryptWithAssociatedData encrypts input to the recipient, associatedData is the AEAD associated data.
+func (ref *HpkeBlockCipher) EncryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	recipientKey, err := Ed25519PublicKeyToX25519(ref.recipientKeyPair.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var senderPrivateKey, senderKey []byte
+	switch ref.Mode {
+	case HpkeModeBase:
+	case HpkeModeAuth:
+		if !ref.senderKeyPair.HasPrivateKey() {
+			return nil, errors.New("cannot authenticate without sender private key")
+		}
+		senderPrivateKey, err = Ed25519PrivateKeyToX25519(ref.senderKeyPair.PrivateKey, ref.engine)
+		if err != nil {
+			return nil, err
+		}
+		senderKey, err = Ed25519PublicKeyToX25519(ref.senderKeyPair.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnknownHpkeMode
+	}
+
+	ikm := make([]byte, X25519KeySize)
+	_, err = io.ReadFull(ref.seed, ikm)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, enc, err := hpkeEncap(ikm, recipientKey, senderPrivateKey, senderKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, nonce, err := hpkeKeySchedule(ref.Mode, ref.Aead, sharedSecret, ref.Info)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(enc, nonce, input, associatedData), nil
+}
+
+// DecryptWithAssociatedData decrypts input with the recipient private key,
+// associatedData must be the one input was encrypted with.
+func (ref *HpkeBlockCipher) DecryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	if !ref.recipientKeyPair.HasPrivateKey() {
+		return nil, errors.New("cannot decrypt without recipient private key")
+	}
+	var senderKey []byte
+	var err error
+	switch ref.Mode {
+	case HpkeModeBase:
+	case HpkeModeAuth:
+		senderKey, err = Ed25519PublicKeyToX25519(ref.senderKeyPair.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnknownHpkeMode
+	}
+	recipientPrivateKey, err := Ed25519PrivateKeyToX25519(ref.recipientKeyPair.PrivateKey, ref.engine)
+	if err != nil {
+		return nil, err
+	}
+	recipientKey, err := Ed25519PublicKeyToX25519(ref.recipientKeyPair.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) < X25519KeySize {
+		return nil, ErrDecryptionFailed
+	}
+	sharedSecret, err := hpkeDecap(input[:X25519KeySize], recipientPrivateKey, recipientKey, senderKey)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	aead, nonce, err := hpkeKeySchedule(ref.Mode, ref.Aead, sharedSecret, ref.Info)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, input[X25519KeySize:], associatedData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// HpkeCryptoEngine is a crypto engine whose block ciphers are HpkeBlockCipher,
+// everything else is done by the wrapped engine.
+type HpkeCryptoEngine struct {
+	CryptoEngine
+	// Mode is the mode of the created block ciphers.
+	Mode HpkeMode
+	// Aead is the AEAD of the created block ciphers.
+	Aead HpkeAead
+	// Info is the info of the created block ciphers.
+	Info []byte
+	seed io.Reader
+}
+
+// NewHpkeCryptoEngine wraps engine into an engine that creates HPKE block ciphers.
+// if crypto engine is nil - default Engine
+// if seed is nil - use crypto/rand instead
+func NewHpkeCryptoEngine(engine CryptoEngine, mode HpkeMode, aead HpkeAead, seed io.Reader) *HpkeCryptoEngine {
+
+	return &HpkeCryptoEngine{CryptoEngines.resolve(engine), mode, aead, nil, seed}
+}
+
+// CreateBlockCipher implemented interface CryptoEngine method
+func (ref *HpkeCryptoEngine) CreateBlockCipher(senderKeyPair *KeyPair, recipientKeyPair *KeyPair) BlockCipher {
+
+	blockCipher := NewHpkeBlockCipher(senderKeyPair, recipientKeyPair, ref.CryptoEngine, ref.seed)
+	blockCipher.Mode = ref.Mode
+	blockCipher.Aead = ref.Aead
+	blockCipher.Info = ref.Info
+	return blockCipher
+}
+
+// Seed implemented interface SeededEngine method
+func (ref *HpkeCryptoEngine) Seed() io.Reader {
+
+	return ref.seed
+}
+
+// hpkeEncap is Encap, or AuthEncap when senderPrivateKey is not nil, with the ephemeral key pair derived from ikm.
+func hpkeEncap(ikm, recipientKey, senderPrivateKey, senderKey []byte) ([]byte, []byte, error) {
+
+	ephemeralPrivateKey, enc := hpkeDeriveKeyPair(ikm)
+	dh, err := X25519(ephemeralPrivateKey, recipientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), recipientKey...)
+	if senderPrivateKey != nil {
+		authDh, err := X25519(senderPrivateKey, recipientKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, authDh...)
+		kemContext = append(kemContext, senderKey...)
+	}
+
+	return hpkeExtractAndExpand(dh, kemContext), enc, nil
+}
+
+// hpkeDecap is Decap, or AuthDecap when senderKey is not nil.
+func hpkeDecap(enc, recipientPrivateKey, recipientKey, senderKey []byte) ([]byte, error) {
+
+	dh, err := X25519(recipientPrivateKey, enc)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), recipientKey...)
+	if senderKey != nil {
+		authDh, err := X25519(recipientPrivateKey, senderKey)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, authDh...)
+		kemContext = append(kemContext, senderKey...)
+	}
+
+	return hpkeExtractAndExpand(dh, kemContext), nil
+}
+
+// hpkeDeriveKeyPair is DeriveKeyPair of DHKEM(X25519, HKDF-SHA256).
+func hpkeDeriveKeyPair(ikm []byte) ([]byte, []byte) {
+
+	prk := hpkeLabeledExtract(hpkeKemSuiteID, nil, "dkp_prk", ikm)
+	privateKey := hpkeLabeledExpand(hpkeKemSuiteID, prk, "sk", nil, X25519KeySize)
+
+	var publicKey, scalar [X25519KeySize]byte
+	copy(scalar[:], privateKey)
+	curve25519.ScalarBaseMult(&publicKey, &scalar)
+
+	return privateKey, publicKey[:]
+}
+
+func hpkeExtractAndExpand(dh, kemContext []byte) []byte {
+
+	prk := hpkeLabeledExtract(hpkeKemSuiteID, nil, "eae_prk", dh)
+	return hpkeLabeledExpand(hpkeKemSuiteID, prk, "shared_secret", kemContext, sha256.Size)
+}
+
+// hpkeKeySchedule is KeySchedule without PSK, it returns the AEAD and the nonce of the first message.
+func hpkeKeySchedule(mode HpkeMode, aeadID HpkeAead, sharedSecret, info []byte) (cipher.AEAD, []byte, error) {
+
+	var keySize int
+	switch aeadID {
+	case HpkeAeadAes128Gcm:
+		keySize = 16
+	case HpkeAeadChaCha20Poly1305:
+		keySize = chacha20poly1305.KeySize
+	default:
+		return nil, nil, ErrUnknownHpkeAead
+	}
+	suiteID := []byte{'H', 'P', 'K', 'E', 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(suiteID[4:], hpkeKemX25519HkdfSha256)
+	binary.BigEndian.PutUint16(suiteID[6:], hpkeKdfHkdfSha256)
+	binary.BigEndian.PutUint16(suiteID[8:], uint16(aeadID))
+
+	context := []byte{byte(mode)}
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "psk_id_hash", nil)...)
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "info_hash", info)...)
+	secret := hpkeLabeledExtract(suiteID, sharedSecret, "secret", nil)
+	key := hpkeLabeledExpand(suiteID, secret, "key", context, keySize)
+	nonce := hpkeLabeledExpand(suiteID, secret, "base_nonce", context, hpkeNonceSize)
+
+	if aeadID == HpkeAeadChaCha20Poly1305 {
+		aead, err := chacha20poly1305.New(key)
+		return aead, nonce, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, nonce, err
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+
+	labeledIkm := append(append(append([]byte{}, hpkeVersionLabel...), suiteID...), label...)
+	return hkdf.Extract(sha256.New, append(labeledIkm, ikm...), salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+
+	labeledInfo := []byte{byte(length >> 8), byte(length)}
+	labeledInfo = append(append(append(labeledInfo, hpkeVersionLabel...), suiteID...), label...)
+	out := make([]byte, length)
+	// length is at most 255 hash sizes, the read cannot fail
+	_, _ = io.ReadFull(hkdf.Expand(sha256.New, prk, append(labeledInfo, info...)), out)
+	return out
+}
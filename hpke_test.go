@@ -0,0 +1,168 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestHpke_Rfc9180BaseVector(t *testing.T) {
+	// RFC 9180 A.1.1: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM, Base mode
+	ikm, _ := hex.DecodeString("7268600d403fce431561aef583ee1613527cff655c1343f29812e66706df3234")
+	recipientPrivateKey, _ := hex.DecodeString("4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	info, _ := hex.DecodeString("4f6465206f6e2061204772656369616e2055726e")
+	var recipientKey, scalar [X25519KeySize]byte
+	copy(scalar[:], recipientPrivateKey)
+	curve25519.ScalarBaseMult(&recipientKey, &scalar)
+
+	sharedSecret, enc, err := hpkeEncap(ikm, recipientKey[:], nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431", hex.EncodeToString(enc))
+	assert.Equal(t, "fe0e18c9f024ce43799ae393c7e8fe8fce9d218875e8227b0187c04e7d2ea1fc", hex.EncodeToString(sharedSecret))
+
+	decapsulated, err := hpkeDecap(enc, recipientPrivateKey, recipientKey[:], nil)
+	assert.Nil(t, err)
+	assert.Equal(t, sharedSecret, decapsulated)
+
+	aead, nonce, err := hpkeKeySchedule(HpkeModeBase, HpkeAeadAes128Gcm, sharedSecret, info)
+	assert.Nil(t, err)
+	assert.Equal(t, "56d890e5accaaf011cff4b7d", hex.EncodeToString(nonce))
+
+	ciphertext := aead.Seal(nil, nonce, []byte("Beauty is truth, truth beauty"), []byte("Count-0"))
+	assert.Equal(t, "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a", hex.EncodeToString(ciphertext))
+}
+
+func TestHpke_Rfc9180AuthVector(t *testing.T) {
+	// RFC 9180 A.1.3: DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM, Auth mode
+	ikm, _ := hex.DecodeString("6e6d8f200ea2fb20c30b003a8b4f433d2f4ed4c2658d5bc8ce2fef718059c9f7")
+	recipientPrivateKey, _ := hex.DecodeString("fdea67cf831f1ca98d8e27b1f6abeb5b7745e9d35348b80fa407ff6958f9137e")
+	senderPrivateKey, _ := hex.DecodeString("dc4a146313cce60a278a5323d321f051c5707e9c45ba21a3479fecdf76fc69dd")
+	info, _ := hex.DecodeString("4f6465206f6e2061204772656369616e2055726e")
+	var recipientKey, senderKey, scalar [X25519KeySize]byte
+	copy(scalar[:], recipientPrivateKey)
+	curve25519.ScalarBaseMult(&recipientKey, &scalar)
+	copy(scalar[:], senderPrivateKey)
+	curve25519.ScalarBaseMult(&senderKey, &scalar)
+	assert.Equal(t, "1632d5c2f71c2b38d0a8fcc359355200caa8b1ffdf28618080466c909cb69b2e", hex.EncodeToString(recipientKey[:]))
+	assert.Equal(t, "8b0c70873dc5aecb7f9ee4e62406a397b350e57012be45cf53b7105ae731790b", hex.EncodeToString(senderKey[:]))
+
+	sharedSecret, enc, err := hpkeEncap(ikm, recipientKey[:], senderPrivateKey, senderKey[:])
+	assert.Nil(t, err)
+	assert.Equal(t, "23fb952571a14a25e3d678140cd0e5eb47a0961bb18afcf85896e5453c312e76", hex.EncodeToString(enc))
+	assert.Equal(t, "2d6db4cf719dc7293fcbf3fa64690708e44e2bebc81f84608677958c0d4448a7", hex.EncodeToString(sharedSecret))
+
+	decapsulated, err := hpkeDecap(enc, recipientPrivateKey, recipientKey[:], senderKey[:])
+	assert.Nil(t, err)
+	assert.Equal(t, sharedSecret, decapsulated)
+
+	aead, nonce, err := hpkeKeySchedule(HpkeModeAuth, HpkeAeadAes128Gcm, sharedSecret, info)
+	assert.Nil(t, err)
+	assert.Equal(t, "a1bc314c1942ade7051ffed0", hex.EncodeToString(nonce))
+
+	ciphertext := aead.Seal(nil, nonce, []byte("Beauty is truth, truth beauty"), []byte("Count-0"))
+	assert.Equal(t, "5fd92cc9d46dbf8943e72a07e42f363ed5f721212cd90bcfd072bfd9f44e06b80fd17824947496e21b680c141b", hex.EncodeToString(ciphertext))
+	// sequence number 1 is XORed into the base nonce
+	nonce[len(nonce)-1] ^= 1
+	ciphertext = aead.Seal(nil, nonce, []byte("Beauty is truth, truth beauty"), []byte("Count-1"))
+	assert.Equal(t, "d3736bb256c19bfa93d79e8f80b7971262cb7c887e35c26370cfed62254369a1b52e3d505b79dd699f002bc8ed", hex.EncodeToString(ciphertext))
+}
+
+func TestHpkeBlockCipher_RoundTrip(t *testing.T) {
+	var _ AeadBlockCipher = (*HpkeBlockCipher)(nil)
+	var _ CryptoEngine = (*HpkeCryptoEngine)(nil)
+
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		for _, mode := range []HpkeMode{HpkeModeBase, HpkeModeAuth} {
+			for _, aead := range []HpkeAead{HpkeAeadAes128Gcm, HpkeAeadChaCha20Poly1305} {
+				sender, err := NewKeyPairByEngine(engine)
+				assert.Nil(t, err)
+				recipient, err := NewKeyPairByEngine(engine)
+				assert.Nil(t, err)
+				hpkeEngine := NewHpkeCryptoEngine(engine, mode, aead, nil)
+				hpkeEngine.Info = []byte("xpx storage")
+
+				encrypted, err := NewBlockCipher(sender, &KeyPair{nil, recipient.PublicKey}, hpkeEngine).Encrypt([]byte(message))
+				assert.Nil(t, err)
+				assert.Len(t, encrypted, X25519KeySize+len(message)+16)
+
+				decrypted, err := NewBlockCipher(&KeyPair{nil, sender.PublicKey}, recipient, hpkeEngine).Decrypt(encrypted)
+				assert.Nil(t, err)
+				assert.Equal(t, message, string(decrypted))
+			}
+		}
+	}
+}
+
+func TestHpkeBlockCipher_AuthModeAuthenticatesSender(t *testing.T) {
+	keyPairs := newEnvelopeKeyPairs(t, 3)
+	sender, recipient, impostor := keyPairs[0], keyPairs[1], keyPairs[2]
+	encrypter := NewHpkeBlockCipher(sender, recipient, nil, nil)
+	encrypter.Mode = HpkeModeAuth
+	encrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+
+	decrypter := NewHpkeBlockCipher(impostor, recipient, nil, nil)
+	decrypter.Mode = HpkeModeAuth
+	_, err = decrypter.Decrypt(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	// the modes are bound to the key schedule
+	decrypter = NewHpkeBlockCipher(sender, recipient, nil, nil)
+	_, err = decrypter.Decrypt(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+	decrypter.Mode = HpkeModeAuth
+	decrypted, err := decrypter.Decrypt(encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(decrypted))
+}
+
+func TestHpkeBlockCipher_DetectsTampering(t *testing.T) {
+	keyPairs := newEnvelopeKeyPairs(t, 2)
+	blockCipher := NewHpkeBlockCipher(nil, keyPairs[1], nil, nil)
+	blockCipher.Info = []byte("info")
+	encrypted, err := blockCipher.EncryptWithAssociatedData([]byte(message), []byte("data"))
+	assert.Nil(t, err)
+
+	for _, i := range []int{0, X25519KeySize - 1, X25519KeySize, len(encrypted) - 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+
+		_, err = blockCipher.DecryptWithAssociatedData(tampered, []byte("data"))
+		assert.Equalf(t, ErrDecryptionFailed, err, "byte %d", i)
+	}
+	_, err = blockCipher.DecryptWithAssociatedData(encrypted, []byte("other"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = blockCipher.DecryptWithAssociatedData(encrypted[:X25519KeySize-1], []byte("data"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	blockCipher.Info = []byte("other")
+	_, err = blockCipher.DecryptWithAssociatedData(encrypted, []byte("data"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
+
+func TestHpkeBlockCipher_Errors(t *testing.T) {
+	keyPairs := newEnvelopeKeyPairs(t, 2)
+	blockCipher := NewHpkeBlockCipher(keyPairs[0], keyPairs[1], nil, nil)
+
+	blockCipher.Mode = HpkeMode(1)
+	_, err := blockCipher.Encrypt([]byte(message))
+	assert.Equal(t, ErrUnknownHpkeMode, err)
+	_, err = blockCipher.Decrypt(make([]byte, 64))
+	assert.Equal(t, ErrUnknownHpkeMode, err)
+
+	blockCipher.Mode = HpkeModeBase
+	blockCipher.Aead = HpkeAead(2)
+	_, err = blockCipher.Encrypt([]byte(message))
+	assert.Equal(t, ErrUnknownHpkeAead, err)
+
+	// the encapsulated key of small order gives an all-zero shared secret
+	blockCipher.Aead = HpkeAeadAes128Gcm
+	_, err = blockCipher.Decrypt(make([]byte, 64))
+	assert.Equal(t, ErrDecryptionFailed, err)
+}
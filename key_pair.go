@@ -14,17 +14,16 @@ type KeyPair struct {
 
 // NewRandomKeyPair creates a random key pair.
 func NewRandomKeyPair() (*KeyPair, error) {
-	return NewKeyPairByEngine(CryptoEngines.DefaultEngine)
+	return NewKeyPairByEngine(CryptoEngines.Default())
 }
 
 // NewKeyPair The public key is calculated from the private key.
 //  The private key must by nil
-// if crypto engine is nil - default Engine
+// if crypto engine is nil - CryptoEngines.Default(), which follows SetDefault.
+// The deprecated CryptoEngines.DefaultEngine is always ed25519-sha3, it does not follow SetDefault.
 func NewKeyPair(privateKey *PrivateKey, publicKey *PublicKey, engine CryptoEngine) (*KeyPair, error) {
 
-	if engine == nil {
-		engine = CryptoEngines.DefaultEngine
-	}
+	engine = CryptoEngines.resolve(engine)
 
 	if publicKey == nil {
 		publicKey = engine.CreateKeyGenerator().DerivePublicKey(privateKey)
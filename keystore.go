@@ -0,0 +1,446 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeystoreVersion is the version of the keystore document written by this library.
+const KeystoreVersion = 1
+
+// KeystoreKdf names the password based key derivation function of a keystore.
+type KeystoreKdf string
+
+const (
+	// KeystoreKdfScrypt derives the keystore keys with scrypt.
+	KeystoreKdfScrypt KeystoreKdf = "scrypt"
+	// KeystoreKdfArgon2id derives the keystore keys with Argon2id.
+	KeystoreKdfArgon2id KeystoreKdf = "argon2id"
+
+	// keystoreCipher is the authenticated encryption of the private key.
+	keystoreCipher = "aes-256-gcm"
+	// keystoreDerivedKeySize is the encryption key (32 bytes) followed by the MAC key (32 bytes).
+	keystoreDerivedKeySize = 64
+	keystoreSaltSize       = 32
+	// keystore KDF parameters are bounded, so a crafted document cannot exhaust memory or CPU:
+	// scrypt needs 128 * N * r bytes, Argon2id needs Memory KiB
+	maxKeystoreScryptN       = 1 << 22
+	maxKeystoreScryptR       = 32
+	maxKeystoreScryptP       = 16
+	maxKeystoreScryptMemory  = 1 << 30
+	maxKeystoreArgon2Time    = 32
+	maxKeystoreArgon2Memory  = 1024 * 1024
+	maxKeystoreArgon2Threads = 64
+)
+
+var (
+	// ErrUnsupportedKeystoreVersion is returned when a keystore has a version this library cannot read.
+	ErrUnsupportedKeystoreVersion = errors.New("unsupported keystore version")
+	// ErrUnknownKeystoreKdf is returned when a keystore names an unknown KDF or cipher.
+	ErrUnknownKeystoreKdf = errors.New("unknown keystore KDF")
+	// ErrInvalidKeystoreKdfParams is returned when the KDF parameters are out of range.
+	ErrInvalidKeystoreKdfParams = errors.New("invalid keystore KDF parameters")
+	// ErrInvalidKeystore is returned when a keystore document is malformed.
+	ErrInvalidKeystore = errors.New("invalid keystore")
+	// ErrWrongKeystorePassword is returned when the password does not open the keystore.
+	ErrWrongKeystorePassword = errors.New("wrong keystore password")
+	// ErrKeystorePublicKeyMismatch is returned when the decrypted private key does not match the stored public key.
+	ErrKeystorePublicKeyMismatch = errors.New("keystore private key does not match its public key")
+)
+
+// KeystoreKdfParams are the parameters of the KDF of a keystore.
+// N, R and P are used by scrypt, Time, Memory (in KiB) and Threads by Argon2id.
+type KeystoreKdfParams struct {
+	Salt    string `json:"salt"`
+	N       int    `json:"n,omitempty"`
+	R       int    `json:"r,omitempty"`
+	P       int    `json:"p,omitempty"`
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// KeystoreCrypto is the encrypted private key of a keystore.
+type KeystoreCrypto struct {
+	Kdf        KeystoreKdf       `json:"kdf"`
+	KdfParams  KeystoreKdfParams `json:"kdfparams"`
+	Cipher     string            `json:"cipher"`
+	Nonce      string            `json:"nonce"`
+	Ciphertext string            `json:"ciphertext"`
+	// Mac is HMAC-SHA256 of the version, engine, public key, nonce and ciphertext under the derived MAC key.
+	// It lets VerifyPassword tell a wrong password apart from a damaged document.
+	Mac string `json:"mac"`
+}
+
+// Keystore is a password encrypted private key stored as a versioned JSON document.
+// The version, engine and public key are authenticated by the MAC and the AEAD,
+// Meta is free form metadata (e.g. a name) and is not authenticated.
+type Keystore struct {
+	Version   int               `json:"version"`
+	Engine    string            `json:"engine"`
+	PublicKey string            `json:"publicKey"`
+	Crypto    KeystoreCrypto    `json:"crypto"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+// KeystoreOptions configures NewKeystore.
+type KeystoreOptions struct {
+	// Kdf is KeystoreKdfScrypt or KeystoreKdfArgon2id.
+	Kdf KeystoreKdf
+	// ScryptN, ScryptR and ScryptP are the scrypt cost parameters.
+	ScryptN, ScryptR, ScryptP int
+	// Argon2Time, Argon2Memory (in KiB) and Argon2Threads are the Argon2id cost parameters.
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	// Engine is the name of the engine the public key is derived with, if it is empty - the default engine.
+	Engine string
+	Meta   map[string]string
+}
+
+// DefaultKeystoreOptions returns scrypt with N = 2^18, r = 8, p = 1 as the KDF.
+func DefaultKeystoreOptions() *KeystoreOptions {
+
+	return &KeystoreOptions{
+		Kdf:           KeystoreKdfScrypt,
+		ScryptN:       1 << 18,
+		ScryptR:       8,
+		ScryptP:       1,
+		Argon2Time:    3,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+	}
+}
+
+// NewKeystore encrypts the private key of keyPair with password.
+// if options is nil - use DefaultKeystoreOptions
+// seed is the source of the salt and the nonce, if seed is nil - use crypto/rand instead
+func NewKeystore(keyPair *KeyPair, password []byte, options *KeystoreOptions, seed io.Reader) (*Keystore, error) {
+
+	if !keyPair.HasPrivateKey() {
+		return nil, errors.New("cannot create keystore without private key")
+	}
+	if options == nil {
+		options = DefaultKeystoreOptions()
+	}
+
+	engineName := options.Engine
+	if engineName == "" {
+		engineName = CryptoEngines.DefaultName()
+	}
+	if _, err := CryptoEngines.Get(engineName); err != nil {
+		return nil, err
+	}
+
+	ref := &Keystore{
+		Version:   KeystoreVersion,
+		Engine:    engineName,
+		PublicKey: keyPair.PublicKey.hex(),
+		Crypto: KeystoreCrypto{
+			Kdf:    options.Kdf,
+			Cipher: keystoreCipher,
+			KdfParams: KeystoreKdfParams{
+				N:       options.ScryptN,
+				R:       options.ScryptR,
+				P:       options.ScryptP,
+				Time:    options.Argon2Time,
+				Memory:  options.Argon2Memory,
+				Threads: options.Argon2Threads,
+			},
+		},
+		Meta: options.Meta,
+	}
+	switch options.Kdf {
+	case KeystoreKdfScrypt:
+		ref.Crypto.KdfParams.Time, ref.Crypto.KdfParams.Memory, ref.Crypto.KdfParams.Threads = 0, 0, 0
+	case KeystoreKdfArgon2id:
+		ref.Crypto.KdfParams.N, ref.Crypto.KdfParams.R, ref.Crypto.KdfParams.P = 0, 0, 0
+	}
+
+	err := ref.encrypt(keyPair.PrivateKey, password, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// LoadKeystore reads a keystore document from r.
+func LoadKeystore(r io.Reader) (*Keystore, error) {
+
+	ref := &Keystore{}
+	if err := json.NewDecoder(r).Decode(ref); err != nil {
+		return nil, ErrInvalidKeystore
+	}
+	if ref.Version != KeystoreVersion {
+		return nil, ErrUnsupportedKeystoreVersion
+	}
+	if ref.Crypto.Cipher != keystoreCipher {
+		return nil, ErrUnknownKeystoreKdf
+	}
+	if err := ref.Crypto.KdfParams.validate(ref.Crypto.Kdf); err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// LoadKeystoreFile reads a keystore document from the file at path.
+func LoadKeystoreFile(path string) (*Keystore, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadKeystore(file)
+}
+
+// Save writes the keystore document to w.
+func (ref *Keystore) Save(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ref)
+}
+
+// SaveFile writes the keystore document to the file at path, the file is readable by the owner only.
+func (ref *Keystore) SaveFile(path string) error {
+
+	b, err := json.MarshalIndent(ref, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// Decrypt opens the keystore with password and returns its key pair.
+func (ref *Keystore) Decrypt(password []byte) (*KeyPair, error) {
+
+	encryptionKey, header, err := ref.openMac(password)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(ref.Crypto.Nonce)
+	if err != nil {
+		return nil, ErrInvalidKeystore
+	}
+	ciphertext, err := hex.DecodeString(ref.Crypto.Ciphertext)
+	if err != nil {
+		return nil, ErrInvalidKeystore
+	}
+	aead, err := newKeystoreAead(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrInvalidKeystore
+	}
+	raw, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrInvalidKeystore
+	}
+
+	engine, err := CryptoEngines.Get(ref.Engine)
+	if err != nil {
+		return nil, err
+	}
+	privateKey := NewPrivateKey(raw)
+	publicKey := engine.CreateKeyGenerator().DerivePublicKey(privateKey)
+	if publicKey.hex() != ref.PublicKey {
+		return nil, ErrKeystorePublicKeyMismatch
+	}
+
+	return NewKeyPair(privateKey, publicKey, engine)
+}
+
+// VerifyPassword checks the MAC of the keystore with password without decrypting the private key.
+// It returns nil if the password is correct.
+func (ref *Keystore) VerifyPassword(password []byte) error {
+
+	_, _, err := ref.openMac(password)
+	return err
+}
+
+// ChangePassword re-encrypts the private key with newPassword, under the same KDF parameters,
+// a new salt and a new nonce.
+// seed is the source of the salt and the nonce, if seed is nil - use crypto/rand instead
+func (ref *Keystore) ChangePassword(oldPassword []byte, newPassword []byte, seed io.Reader) error {
+
+	keyPair, err := ref.Decrypt(oldPassword)
+	if err != nil {
+		return err
+	}
+
+	changed := *ref
+	if err := changed.encrypt(keyPair.PrivateKey, newPassword, seed); err != nil {
+		return err
+	}
+	*ref = changed
+
+	return nil
+}
+
+// encrypt fills the salt, nonce, ciphertext and MAC of the keystore.
+func (ref *Keystore) encrypt(privateKey *PrivateKey, password []byte, seed io.Reader) error {
+
+	if seed == nil {
+		seed = rand.Reader
+	}
+	if err := ref.Crypto.KdfParams.validate(ref.Crypto.Kdf); err != nil {
+		return err
+	}
+
+	salt := make([]byte, keystoreSaltSize)
+	if _, err := io.ReadFull(seed, salt); err != nil {
+		return err
+	}
+	ref.Crypto.KdfParams.Salt = hex.EncodeToString(salt)
+	derivedKey, err := ref.Crypto.KdfParams.deriveKey(ref.Crypto.Kdf, password)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newKeystoreAead(derivedKey[:32])
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(seed, nonce); err != nil {
+		return err
+	}
+	header := ref.header()
+	ciphertext := aead.Seal(nil, nonce, privateKey.Raw, header)
+
+	ref.Crypto.Nonce = hex.EncodeToString(nonce)
+	ref.Crypto.Ciphertext = hex.EncodeToString(ciphertext)
+	ref.Crypto.Mac = hex.EncodeToString(keystoreMac(derivedKey[32:], header, nonce, ciphertext))
+
+	return nil
+}
+
+// openMac derives the keys from password and checks the MAC.
+// It returns the encryption key and the authenticated header.
+func (ref *Keystore) openMac(password []byte) ([]byte, []byte, error) {
+
+	if ref.Version != KeystoreVersion {
+		return nil, nil, ErrUnsupportedKeystoreVersion
+	}
+	if err := ref.Crypto.KdfParams.validate(ref.Crypto.Kdf); err != nil {
+		return nil, nil, err
+	}
+	derivedKey, err := ref.Crypto.KdfParams.deriveKey(ref.Crypto.Kdf, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hex.DecodeString(ref.Crypto.Nonce)
+	if err != nil {
+		return nil, nil, ErrInvalidKeystore
+	}
+	ciphertext, err := hex.DecodeString(ref.Crypto.Ciphertext)
+	if err != nil {
+		return nil, nil, ErrInvalidKeystore
+	}
+	mac, err := hex.DecodeString(ref.Crypto.Mac)
+	if err != nil {
+		return nil, nil, ErrInvalidKeystore
+	}
+
+	header := ref.header()
+	if !hmac.Equal(mac, keystoreMac(derivedKey[32:], header, nonce, ciphertext)) {
+		return nil, nil, ErrWrongKeystorePassword
+	}
+
+	return derivedKey[:32], header, nil
+}
+
+// header is the authenticated data of the keystore: version | len(engine) | engine | public key.
+func (ref *Keystore) header() []byte {
+
+	header := []byte{byte(ref.Version), byte(len(ref.Engine))}
+	header = append(header, ref.Engine...)
+	return append(header, ref.PublicKey...)
+}
+
+func (ref *KeystoreKdfParams) validate(kdf KeystoreKdf) error {
+
+	switch kdf {
+	case KeystoreKdfScrypt:
+		// N must be a power of 2 greater than 1
+		if ref.N <= 1 || ref.N&(ref.N-1) != 0 || ref.N > maxKeystoreScryptN ||
+			ref.R <= 0 || ref.R > maxKeystoreScryptR || ref.P <= 0 || ref.P > maxKeystoreScryptP ||
+			128*uint64(ref.N)*uint64(ref.R) > maxKeystoreScryptMemory {
+			return ErrInvalidKeystoreKdfParams
+		}
+	case KeystoreKdfArgon2id:
+		if ref.Time == 0 || ref.Time > maxKeystoreArgon2Time || ref.Threads == 0 || ref.Threads > maxKeystoreArgon2Threads ||
+			ref.Memory < 8*uint32(ref.Threads) || ref.Memory > maxKeystoreArgon2Memory {
+			return ErrInvalidKeystoreKdfParams
+		}
+	default:
+		return ErrUnknownKeystoreKdf
+	}
+
+	return nil
+}
+
+func (ref *KeystoreKdfParams) deriveKey(kdf KeystoreKdf, password []byte) ([]byte, error) {
+
+	salt, err := hex.DecodeString(ref.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, ErrInvalidKeystore
+	}
+
+	return ref.deriveKeyWithSalt(kdf, password, salt, keystoreDerivedKeySize)
+}
+
+// deriveKeyWithSalt derives keySize bytes from password and salt, the Salt of the parameters is not used.
+func (ref *KeystoreKdfParams) deriveKeyWithSalt(kdf KeystoreKdf, password []byte, salt []byte, keySize int) ([]byte, error) {
+
+	switch kdf {
+	case KeystoreKdfScrypt:
+		return scrypt.Key(password, salt, ref.N, ref.R, ref.P, keySize)
+	case KeystoreKdfArgon2id:
+		return argon2.IDKey(password, salt, ref.Time, ref.Memory, ref.Threads, uint32(keySize)), nil
+	}
+
+	return nil, ErrUnknownKeystoreKdf
+}
+
+func newKeystoreAead(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func keystoreMac(key []byte, inputs ...[]byte) []byte {
+
+	mac := hmac.New(sha256.New, key)
+	for _, b := range inputs {
+		mac.Write(b)
+	}
+
+	return mac.Sum(nil)
+}
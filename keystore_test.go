@@ -0,0 +1,230 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var keystorePassword = []byte("correct horse battery staple")
+
+// testKeystoreOptions keeps the KDFs cheap, so the tests run fast
+func testKeystoreOptions(kdf KeystoreKdf) *KeystoreOptions {
+
+	return &KeystoreOptions{
+		Kdf:           kdf,
+		ScryptN:       1 << 10,
+		ScryptR:       8,
+		ScryptP:       1,
+		Argon2Time:    1,
+		Argon2Memory:  64,
+		Argon2Threads: 1,
+		Meta:          map[string]string{"name": "storage node"},
+	}
+}
+
+func TestKeystore_RoundTrip(t *testing.T) {
+	for _, kdf := range []KeystoreKdf{KeystoreKdfScrypt, KeystoreKdfArgon2id} {
+		kp, err := NewRandomKeyPair()
+		assert.Nil(t, err)
+		keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(kdf), nil)
+		assert.Nil(t, err)
+
+		buffer := &bytes.Buffer{}
+		assert.Nil(t, keystore.Save(buffer))
+		assert.NotContains(t, buffer.String(), kp.PrivateKey.String())
+		loaded, err := LoadKeystore(buffer)
+		assert.Nil(t, err)
+		assert.Equal(t, keystore, loaded)
+
+		assert.Equal(t, KeystoreVersion, loaded.Version)
+		assert.Equal(t, Ed25519Sha3EngineName, loaded.Engine)
+		assert.Equal(t, kdf, loaded.Crypto.Kdf)
+		assert.Equal(t, "storage node", loaded.Meta["name"])
+		assert.Nil(t, loaded.VerifyPassword(keystorePassword))
+		decrypted, err := loaded.Decrypt(keystorePassword)
+		assert.Nil(t, err)
+		assert.Equal(t, kp, decrypted)
+	}
+}
+
+func TestKeystore_WrongPassword(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(KeystoreKdfArgon2id), nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ErrWrongKeystorePassword, keystore.VerifyPassword([]byte("wrong")))
+	_, err = keystore.Decrypt([]byte("wrong"))
+	assert.Equal(t, ErrWrongKeystorePassword, err)
+}
+
+func TestKeystore_ChangePassword(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(KeystoreKdfScrypt), nil)
+	assert.Nil(t, err)
+	salt := keystore.Crypto.KdfParams.Salt
+
+	assert.Equal(t, ErrWrongKeystorePassword, keystore.ChangePassword([]byte("wrong"), []byte("new password"), nil))
+	assert.Nil(t, keystore.VerifyPassword(keystorePassword))
+
+	assert.Nil(t, keystore.ChangePassword(keystorePassword, []byte("new password"), nil))
+
+	assert.NotEqual(t, salt, keystore.Crypto.KdfParams.Salt)
+	assert.Equal(t, ErrWrongKeystorePassword, keystore.VerifyPassword(keystorePassword))
+	decrypted, err := keystore.Decrypt([]byte("new password"))
+	assert.Nil(t, err)
+	assert.Equal(t, kp, decrypted)
+}
+
+func TestKeystore_UsesEngineOfKeyPair(t *testing.T) {
+	engine := CryptoEngines.Ed25519Sha512Engine
+	kp, err := NewKeyPairByEngine(engine)
+	assert.Nil(t, err)
+	options := testKeystoreOptions(KeystoreKdfScrypt)
+	options.Engine = Ed25519Sha512EngineName
+
+	keystore, err := NewKeystore(kp, keystorePassword, options, nil)
+	assert.Nil(t, err)
+	decrypted, err := keystore.Decrypt(keystorePassword)
+
+	assert.Nil(t, err)
+	assert.Equal(t, kp.PublicKey, decrypted.PublicKey)
+
+	options.Engine = "ed448"
+	_, err = NewKeystore(kp, keystorePassword, options, nil)
+	assert.Equal(t, ErrUnknownCryptoEngine, err)
+}
+
+func TestKeystore_DetectsTampering(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(KeystoreKdfScrypt), nil)
+	assert.Nil(t, err)
+	other, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+
+	tampered := *keystore
+	tampered.PublicKey = other.PublicKey.hex()
+	assert.Equal(t, ErrWrongKeystorePassword, tampered.VerifyPassword(keystorePassword))
+
+	tampered = *keystore
+	tampered.Engine = Ed25519Sha512EngineName
+	_, err = tampered.Decrypt(keystorePassword)
+	assert.Equal(t, ErrWrongKeystorePassword, err)
+
+	tampered = *keystore
+	tampered.Crypto.Ciphertext = "00" + tampered.Crypto.Ciphertext[2:]
+	_, err = tampered.Decrypt(keystorePassword)
+	assert.Equal(t, ErrWrongKeystorePassword, err)
+}
+
+func TestLoadKeystore_Rejects(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(KeystoreKdfScrypt), nil)
+	assert.Nil(t, err)
+
+	load := func(modify func(document map[string]interface{})) error {
+		b, err := json.Marshal(keystore)
+		assert.Nil(t, err)
+		document := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(b, &document))
+		modify(document)
+		b, err = json.Marshal(document)
+		assert.Nil(t, err)
+		_, err = LoadKeystore(bytes.NewReader(b))
+		return err
+	}
+	crypto := func(document map[string]interface{}) map[string]interface{} {
+		return document["crypto"].(map[string]interface{})
+	}
+	params := func(document map[string]interface{}) map[string]interface{} {
+		return crypto(document)["kdfparams"].(map[string]interface{})
+	}
+
+	assert.Nil(t, load(func(document map[string]interface{}) {}))
+	assert.Equal(t, ErrUnsupportedKeystoreVersion, load(func(document map[string]interface{}) {
+		document["version"] = 2
+	}))
+	assert.Equal(t, ErrUnknownKeystoreKdf, load(func(document map[string]interface{}) {
+		crypto(document)["kdf"] = "pbkdf2"
+	}))
+	assert.Equal(t, ErrUnknownKeystoreKdf, load(func(document map[string]interface{}) {
+		crypto(document)["cipher"] = "aes-128-ctr"
+	}))
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, load(func(document map[string]interface{}) {
+		params(document)["n"] = 1000
+	}))
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, load(func(document map[string]interface{}) {
+		params(document)["n"] = 1 << 30
+	}))
+	_, err = LoadKeystore(bytes.NewReader([]byte("{")))
+	assert.Equal(t, ErrInvalidKeystore, err)
+}
+
+func TestKeystore_RejectsHostileKdfParams(t *testing.T) {
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+
+	for _, hostile := range []KeystoreKdfParams{
+		// 128 * N * r is 2^49 bytes
+		{N: 1 << 22, R: 1 << 20, P: 1},
+		// 128 * N * r is 4 GiB
+		{N: 1 << 22, R: 8, P: 1},
+		{N: 1 << 10, R: maxKeystoreScryptR + 1, P: 1},
+		{N: 1 << 10, R: 8, P: maxKeystoreScryptP + 1},
+		{Time: maxKeystoreArgon2Time + 1, Memory: 64, Threads: 1},
+		{Time: 1, Memory: maxKeystoreArgon2Memory + 1, Threads: 1},
+		{Time: 1, Memory: 8 * 255, Threads: 255},
+	} {
+		kdf := KeystoreKdfArgon2id
+		if hostile.N != 0 {
+			kdf = KeystoreKdfScrypt
+		}
+		keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(kdf), nil)
+		assert.Nil(t, err)
+		hostile.Salt = keystore.Crypto.KdfParams.Salt
+		keystore.Crypto.KdfParams = hostile
+
+		buffer := &bytes.Buffer{}
+		assert.Nil(t, keystore.Save(buffer))
+		_, err = LoadKeystore(bytes.NewReader(buffer.Bytes()))
+		assert.Equalf(t, ErrInvalidKeystoreKdfParams, err, "%+v", hostile)
+		assert.Equal(t, ErrInvalidKeystoreKdfParams, keystore.VerifyPassword(keystorePassword))
+		_, err = keystore.Decrypt(keystorePassword)
+		assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+	}
+}
+
+func TestKeystore_SaveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key.json")
+	kp, err := NewRandomKeyPair()
+	assert.Nil(t, err)
+	keystore, err := NewKeystore(kp, keystorePassword, testKeystoreOptions(KeystoreKdfArgon2id), nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, keystore.SaveFile(path))
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadKeystoreFile(path)
+	assert.Nil(t, err)
+	decrypted, err := loaded.Decrypt(keystorePassword)
+	assert.Nil(t, err)
+	assert.Equal(t, kp, decrypted)
+}
@@ -0,0 +1,316 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MnemonicEnglish is the name of the BIP-39 English word list.
+	MnemonicEnglish = "english"
+	// mnemonicWordlistSize is the number of words in every BIP-39 word list.
+	mnemonicWordlistSize = 2048
+	// mnemonicSeedIterations is the number of PBKDF2 iterations BIP-39 derives the seed with.
+	mnemonicSeedIterations = 2048
+	// MnemonicSeedSize is the size of the seed derived from a mnemonic.
+	MnemonicSeedSize = 64
+)
+
+var (
+	// ErrInvalidMnemonicSize is returned when a mnemonic has not 12, 15, 18, 21 or 24 words.
+	ErrInvalidMnemonicSize = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	// ErrInvalidMnemonicEntropySize is returned when the entropy is not 16, 20, 24, 28 or 32 bytes.
+	ErrInvalidMnemonicEntropySize = errors.New("mnemonic entropy must have 16, 20, 24, 28 or 32 bytes")
+	// ErrUnknownMnemonicWord is returned when a mnemonic has a word that is not in the word list.
+	ErrUnknownMnemonicWord = errors.New("mnemonic has a word that is not in the word list")
+	// ErrInvalidMnemonicChecksum is returned when the checksum of a mnemonic does not match.
+	ErrInvalidMnemonicChecksum = errors.New("mnemonic checksum is not valid")
+	// ErrInvalidWordlist is returned when a word list has not 2048 unique words.
+	ErrInvalidWordlist = errors.New("word list must have 2048 unique words")
+	// ErrUnknownWordlist is returned when no word list is registered under the requested language.
+	ErrUnknownWordlist = errors.New("unknown mnemonic word list")
+	// ErrWordlistAlreadyRegistered is returned when a word list is registered under a language twice.
+	ErrWordlistAlreadyRegistered = errors.New("mnemonic word list is already registered")
+)
+
+// Wordlist is a BIP-39 word list.
+// Words of non English lists must be in Unicode NFKD form, as BIP-39 requires.
+type Wordlist struct {
+	language  string
+	words     []string
+	index     map[string]int
+	separator string
+}
+
+// NewWordlist creates a word list of 2048 unique words.
+// separator joins the words of a generated mnemonic, if separator is empty - use a space.
+func NewWordlist(language string, words []string, separator string) (*Wordlist, error) {
+
+	if len(words) != mnemonicWordlistSize {
+		return nil, ErrInvalidWordlist
+	}
+	if separator == "" {
+		separator = " "
+	}
+
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		if word == "" {
+			return nil, ErrInvalidWordlist
+		}
+		if _, ok := index[word]; ok {
+			return nil, ErrInvalidWordlist
+		}
+		index[word] = i
+	}
+
+	list := make([]string, len(words))
+	copy(list, words)
+
+	return &Wordlist{language, list, index, separator}, nil
+}
+
+// Language returns the language the word list is registered under.
+func (ref *Wordlist) Language() string {
+
+	return ref.language
+}
+
+// Word returns the word at index.
+func (ref *Wordlist) Word(index int) string {
+
+	return ref.words[index]
+}
+
+// Index returns the index of word and reports whether the word list has it.
+func (ref *Wordlist) Index(word string) (int, bool) {
+
+	i, ok := ref.index[word]
+	return i, ok
+}
+
+// EnglishWordlist is the BIP-39 English word list.
+var EnglishWordlist = mustNewWordlist(MnemonicEnglish, strings.Fields(englishMnemonicWords), " ")
+
+func mustNewWordlist(language string, words []string, separator string) *Wordlist {
+
+	wordlist, err := NewWordlist(language, words, separator)
+	if err != nil {
+		panic(err)
+	}
+
+	return wordlist
+}
+
+var (
+	wordlistsMutex sync.RWMutex
+	wordlists      = map[string]*Wordlist{MnemonicEnglish: EnglishWordlist}
+)
+
+// RegisterWordlist makes a word list available under its language.
+func RegisterWordlist(wordlist *Wordlist) error {
+
+	if wordlist == nil {
+		return ErrInvalidWordlist
+	}
+
+	wordlistsMutex.Lock()
+	defer wordlistsMutex.Unlock()
+
+	if _, ok := wordlists[wordlist.language]; ok {
+		return ErrWordlistAlreadyRegistered
+	}
+	wordlists[wordlist.language] = wordlist
+	return nil
+}
+
+// GetWordlist returns the word list registered under language.
+func GetWordlist(language string) (*Wordlist, error) {
+
+	wordlistsMutex.RLock()
+	defer wordlistsMutex.RUnlock()
+
+	wordlist, ok := wordlists[language]
+	if !ok {
+		return nil, ErrUnknownWordlist
+	}
+	return wordlist, nil
+}
+
+// WordlistLanguages returns the sorted languages of the registered word lists.
+func WordlistLanguages() []string {
+
+	wordlistsMutex.RLock()
+	defer wordlistsMutex.RUnlock()
+
+	languages := make([]string, 0, len(wordlists))
+	for language := range wordlists {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// NewMnemonic encodes entropy of 16, 20, 24, 28 or 32 bytes into a mnemonic of 12, 15, 18, 21 or 24 words.
+// if wordlist is nil - use EnglishWordlist
+func NewMnemonic(entropy []byte, wordlist *Wordlist) (string, error) {
+
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", ErrInvalidMnemonicEntropySize
+	}
+	if wordlist == nil {
+		wordlist = EnglishWordlist
+	}
+
+	// the checksum is the first entropy bits / 32 bits of SHA-256(entropy)
+	checksumBits := uint(len(entropy) / 4)
+	hash := sha256.Sum256(entropy)
+	bits := new(big.Int).SetBytes(entropy)
+	bits.Lsh(bits, checksumBits)
+	bits.Or(bits, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (len(entropy)*8 + int(checksumBits)) / 11
+	words := make([]string, count)
+	mask := big.NewInt(mnemonicWordlistSize - 1)
+	index := new(big.Int)
+	for i := count - 1; i >= 0; i-- {
+		index.And(bits, mask)
+		words[i] = wordlist.Word(int(index.Int64()))
+		bits.Rsh(bits, 11)
+	}
+
+	return strings.Join(words, wordlist.separator), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic into its entropy and verifies its checksum.
+// if wordlist is nil - use EnglishWordlist
+func MnemonicToEntropy(mnemonic string, wordlist *Wordlist) ([]byte, error) {
+
+	if wordlist == nil {
+		wordlist = EnglishWordlist
+	}
+
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrInvalidMnemonicSize
+	}
+
+	bits := new(big.Int)
+	for _, word := range words {
+		index, ok := wordlist.Index(word)
+		if !ok {
+			return nil, ErrUnknownMnemonicWord
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1))
+	bits.Rsh(bits, checksumBits)
+
+	entropy := make([]byte, len(words)*4/3)
+	raw := bits.Bytes()
+	copy(entropy[len(entropy)-len(raw):], raw)
+
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum.Int64() {
+		return nil, ErrInvalidMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// ValidateMnemonic checks that every word of mnemonic is in the word list and the checksum matches.
+// if wordlist is nil - use EnglishWordlist
+func ValidateMnemonic(mnemonic string, wordlist *Wordlist) error {
+
+	_, err := MnemonicToEntropy(mnemonic, wordlist)
+	return err
+}
+
+// MnemonicToSeed validates mnemonic and derives the 64 bytes seed from it with
+// PBKDF2-HMAC-SHA512("mnemonic" + passphrase) as BIP-39 does.
+// A non ASCII passphrase must be in Unicode NFKD form.
+// if wordlist is nil - use EnglishWordlist
+func MnemonicToSeed(mnemonic string, passphrase string, wordlist *Wordlist) ([]byte, error) {
+
+	if err := ValidateMnemonic(mnemonic, wordlist); err != nil {
+		return nil, err
+	}
+
+	// the words are joined by the separator of the word list, as they were generated
+	if wordlist == nil {
+		wordlist = EnglishWordlist
+	}
+	normalized := strings.Join(strings.Fields(mnemonic), wordlist.separator)
+
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), mnemonicSeedIterations, MnemonicSeedSize, sha512.New), nil
+}
+
+// GenerateMnemonic generates a mnemonic of 12, 15, 18, 21 or 24 words from the seed of the generator.
+// if wordlist is nil - use EnglishWordlist
+func (ref *Ed25519KeyGenerator) GenerateMnemonic(words int, wordlist *Wordlist) (string, error) {
+
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", ErrInvalidMnemonicSize
+	}
+
+	entropy := make([]byte, words*4/3)
+	_, err := io.ReadFull(ref.seed, entropy)
+	if err != nil {
+		return "", err
+	}
+
+	return NewMnemonic(entropy, wordlist)
+}
+
+// KeyPairFromMnemonic creates the key pair whose private key is the first 32 bytes of the mnemonic seed.
+// if wordlist is nil - use EnglishWordlist
+func (ref *Ed25519KeyGenerator) KeyPairFromMnemonic(mnemonic string, passphrase string, wordlist *Wordlist) (*KeyPair, error) {
+
+	seed, err := MnemonicToSeed(mnemonic, passphrase, wordlist)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey := NewPrivateKey(seed[:32])
+	return &KeyPair{privateKey, ref.DerivePublicKey(privateKey)}, nil
+}
+
+// DeriveKeyPairFromMnemonic derives the key pair at path (e.g. m/44'/43'/0'/0'/0') from the mnemonic seed
+// with SLIP-0010.
+// if wordlist is nil - use EnglishWordlist
+func (ref *Ed25519KeyGenerator) DeriveKeyPairFromMnemonic(mnemonic string, passphrase string, path string, wordlist *Wordlist) (*KeyPair, error) {
+
+	derivationPath, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := MnemonicToSeed(mnemonic, passphrase, wordlist)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed, nil)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.Derive(derivationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{key.PrivateKey, ref.DerivePublicKey(key.PrivateKey)}, nil
+}
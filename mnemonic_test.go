@@ -0,0 +1,168 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// BIP-39 test vectors of the Trezor reference implementation, the passphrase is "TREZOR"
+var mnemonicVectors = []struct {
+	entropy, mnemonic, seed string
+}{
+	{"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+	{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+	{"9e885d952ad362caeb4efe34a8e91bd2",
+		"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+		"274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028"},
+	{"6610b25967cdcca9d59875f5cb50b0ea75433311869e930b",
+		"gravity machine north sort system female filter attitude volume fold club stay feature office ecology stable narrow fog",
+		"628c3827a8823298ee685db84f55caa34b5cc195a778e52d45f59bcf75aba68e4d7590e101dc414bc1bbd5737666fbbef35d1f1903953b66624f910feef245ac"},
+	{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+		"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+}
+
+func TestEnglishWordlist(t *testing.T) {
+	assert.Equal(t, "abandon", EnglishWordlist.Word(0))
+	assert.Equal(t, "zoo", EnglishWordlist.Word(mnemonicWordlistSize-1))
+	index, ok := EnglishWordlist.Index("winner")
+	assert.True(t, ok)
+	assert.Equal(t, "winner", EnglishWordlist.Word(index))
+
+	wordlist, err := GetWordlist(MnemonicEnglish)
+	assert.Nil(t, err)
+	assert.Equal(t, EnglishWordlist, wordlist)
+}
+
+func TestMnemonic_MatchesBIP39Vectors(t *testing.T) {
+	for _, vector := range mnemonicVectors {
+		entropy, err := hex.DecodeString(vector.entropy)
+		assert.Nil(t, err)
+
+		mnemonic, err := NewMnemonic(entropy, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.mnemonic, mnemonic)
+
+		decoded, err := MnemonicToEntropy(mnemonic, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, entropy, decoded)
+
+		seed, err := MnemonicToSeed(mnemonic, "TREZOR", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.seed, hex.EncodeToString(seed))
+	}
+}
+
+func TestMnemonic_Rejects(t *testing.T) {
+	_, err := NewMnemonic(make([]byte, 15), nil)
+	assert.Equal(t, ErrInvalidMnemonicEntropySize, err)
+	_, err = NewMnemonic(make([]byte, 36), nil)
+	assert.Equal(t, ErrInvalidMnemonicEntropySize, err)
+
+	assert.Equal(t, ErrInvalidMnemonicSize, ValidateMnemonic(strings.Repeat("abandon ", 11), nil))
+	assert.Equal(t, ErrInvalidMnemonicSize, ValidateMnemonic(strings.Repeat("abandon ", 27), nil))
+	assert.Equal(t, ErrUnknownMnemonicWord, ValidateMnemonic(strings.Repeat("abandon ", 11)+"bitcoin", nil))
+	assert.Equal(t, ErrInvalidMnemonicChecksum, ValidateMnemonic(strings.Repeat("abandon ", 12), nil))
+	assert.Nil(t, ValidateMnemonic("  abandon abandon abandon abandon abandon abandon\tabandon abandon abandon abandon abandon about\n", nil))
+
+	_, err = MnemonicToSeed(strings.Repeat("abandon ", 12), "", nil)
+	assert.Equal(t, ErrInvalidMnemonicChecksum, err)
+}
+
+func TestEd25519KeyGenerator_GenerateMnemonicReadsEngineSeed(t *testing.T) {
+	for _, words := range []int{12, 15, 18, 21, 24} {
+		entropy := bytes.Repeat([]byte{0xff}, words*4/3)
+		generator := NewEd25519SeedCryptoEngine(bytes.NewReader(entropy)).CreateKeyGenerator().(*Ed25519KeyGenerator)
+
+		mnemonic, err := generator.GenerateMnemonic(words, nil)
+
+		assert.Nil(t, err)
+		assert.Len(t, strings.Fields(mnemonic), words)
+		expected, err := NewMnemonic(entropy, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, mnemonic)
+	}
+
+	_, err := NewEd25519KeyGenerator(nil).GenerateMnemonic(13, nil)
+	assert.Equal(t, ErrInvalidMnemonicSize, err)
+}
+
+func TestEd25519KeyGenerator_KeyPairFromMnemonic(t *testing.T) {
+	vector := mnemonicVectors[0]
+	generator := NewEd25519KeyGenerator(nil)
+
+	kp, err := generator.KeyPairFromMnemonic(vector.mnemonic, "TREZOR", nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, vector.seed[:64], kp.PrivateKey.String())
+	assert.Equal(t, generator.DerivePublicKey(kp.PrivateKey), kp.PublicKey)
+
+	other, err := generator.KeyPairFromMnemonic(vector.mnemonic, "", nil)
+	assert.Nil(t, err)
+	assert.NotEqual(t, kp.PrivateKey, other.PrivateKey)
+}
+
+func TestEd25519KeyGenerator_DeriveKeyPairFromMnemonic(t *testing.T) {
+	vector := mnemonicVectors[0]
+	seed, err := hex.DecodeString(vector.seed)
+	assert.Nil(t, err)
+	generator := CryptoEngines.Ed25519Sha512Engine.CreateKeyGenerator().(*Ed25519KeyGenerator)
+
+	for account := 0; account < 3; account++ {
+		path := fmt.Sprintf("m/44'/43'/%d'/0'/0'", account)
+		kp, err := generator.DeriveKeyPairFromMnemonic(vector.mnemonic, "TREZOR", path, nil)
+		assert.Nil(t, err)
+
+		expected, err := DeriveKeyPair(seed, path, CryptoEngines.Ed25519Sha512Engine)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, kp)
+	}
+
+	_, err = generator.DeriveKeyPairFromMnemonic(vector.mnemonic, "TREZOR", "m/44'/43'/0", nil)
+	assert.Equal(t, ErrNonHardenedDerivation, err)
+}
+
+func TestRegisterWordlist(t *testing.T) {
+	words := make([]string, mnemonicWordlistSize)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%04d", i)
+	}
+	_, err := NewWordlist("test-short", words[:100], "")
+	assert.Equal(t, ErrInvalidWordlist, err)
+	duplicated := append([]string{}, words...)
+	duplicated[1] = duplicated[0]
+	_, err = NewWordlist("test-duplicated", duplicated, "")
+	assert.Equal(t, ErrInvalidWordlist, err)
+
+	wordlist, err := NewWordlist("test-numbers", words, "　")
+	assert.Nil(t, err)
+	assert.Nil(t, RegisterWordlist(wordlist))
+	assert.Equal(t, ErrWordlistAlreadyRegistered, RegisterWordlist(wordlist))
+	assert.Contains(t, WordlistLanguages(), "test-numbers")
+	registered, err := GetWordlist("test-numbers")
+	assert.Nil(t, err)
+	_, err = GetWordlist("klingon")
+	assert.Equal(t, ErrUnknownWordlist, err)
+
+	entropy := make([]byte, 16)
+	mnemonic, err := NewMnemonic(entropy, registered)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("w0000　", 11)+"w0003", mnemonic)
+	decoded, err := MnemonicToEntropy(mnemonic, registered)
+	assert.Nil(t, err)
+	assert.Equal(t, entropy, decoded)
+	assert.Equal(t, ErrUnknownMnemonicWord, ValidateMnemonic(mnemonic, nil))
+}
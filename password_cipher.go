@@ -0,0 +1,213 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The password format is self-describing, so a ciphertext can be decrypted with the password alone:
+//
+//	"XPXP" | version | suite | kdf | kdf parameters | salt | nonce | ciphertext and tag
+//
+// The kdf parameters are three big endian uint32: N, r and p of scrypt or time, memory (in KiB) and threads of Argon2id.
+// The header is the associated data of the AEAD.
+const (
+	// passwordFormatVersion is the version of the password format.
+	passwordFormatVersion = 1
+	// passwordKdfScrypt and passwordKdfArgon2id identify the KDF in the header.
+	passwordKdfScrypt   = 1
+	passwordKdfArgon2id = 2
+	// passwordSaltSize is the size of the KDF salt.
+	passwordSaltSize = 32
+	// passwordKeySize is the size of the AEAD key.
+	passwordKeySize = 32
+)
+
+var (
+	// passwordMagic starts every password ciphertext.
+	passwordMagic = []byte("XPXP")
+	// passwordSaltStart is the size of "XPXP" | version | suite | kdf | kdf parameters.
+	passwordSaltStart = len(passwordMagic) + 3 + 3*4
+)
+
+// PasswordBlockCipher implements BlockCipher with a key derived from a password by scrypt or Argon2id.
+// The KDF, its parameters and the cipher suite are recorded in the ciphertext,
+// so Decrypt does not depend on the fields of the block cipher.
+type PasswordBlockCipher struct {
+	// Kdf is KeystoreKdfScrypt or KeystoreKdfArgon2id.
+	Kdf KeystoreKdf
+	// ScryptN, ScryptR and ScryptP are the scrypt cost parameters.
+	ScryptN, ScryptR, ScryptP int
+	// Argon2Time, Argon2Memory (in KiB) and Argon2Threads are the Argon2id cost parameters.
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	// Suite is the AEAD of the ciphertext.
+	Suite    CipherSuite
+	password []byte
+	seed     io.Reader
+}
+
+// NewPasswordBlockCipherWithSeed creates PasswordBlockCipher with Argon2id and CipherSuiteAes256Gcm,
+// the cost parameters are the ones of DefaultKeystoreOptions.
+// if seed is nil - use crypto/rand instead
+func NewPasswordBlockCipherWithSeed(password []byte, seed io.Reader) *PasswordBlockCipher {
+	if seed == nil {
+		seed = rand.Reader
+	}
+	options := DefaultKeystoreOptions()
+
+	return &PasswordBlockCipher{
+		KeystoreKdfArgon2id,
+		options.ScryptN,
+		options.ScryptR,
+		options.ScryptP,
+		options.Argon2Time,
+		options.Argon2Memory,
+		options.Argon2Threads,
+		CipherSuiteAes256Gcm,
+		password,
+		seed,
+	}
+}
+
+// Encrypt slice byte
+func (ref *PasswordBlockCipher) Encrypt(input []byte) ([]byte, error) {
+
+	return ref.EncryptWithAssociatedData(input, nil)
+}
+
+// Decrypt slice byte
+func (ref *PasswordBlockCipher) Decrypt(input []byte) ([]byte, error) {
+
+	return ref.DecryptWithAssociatedData(input, nil)
+}
+
+// EncryptWithAssociatedData encrypts input with the password, associatedData is authenticated but not encrypted.
+func (ref *PasswordBlockCipher) EncryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	if !ref.Suite.isKnown() {
+		return nil, ErrUnknownCipherSuite
+	}
+	header := make([]byte, passwordSaltStart+passwordSaltSize+ref.Suite.nonceSize())
+	copy(header, passwordMagic)
+	header[len(passwordMagic)] = passwordFormatVersion
+	header[len(passwordMagic)+1] = byte(ref.Suite)
+	var params [3]uint32
+	switch ref.Kdf {
+	case KeystoreKdfScrypt:
+		header[len(passwordMagic)+2] = passwordKdfScrypt
+		if uint64(ref.ScryptN) > math.MaxUint32 || uint64(ref.ScryptR) > math.MaxUint32 || uint64(ref.ScryptP) > math.MaxUint32 {
+			return nil, ErrInvalidKeystoreKdfParams
+		}
+		params = [3]uint32{uint32(ref.ScryptN), uint32(ref.ScryptR), uint32(ref.ScryptP)}
+	case KeystoreKdfArgon2id:
+		header[len(passwordMagic)+2] = passwordKdfArgon2id
+		params = [3]uint32{ref.Argon2Time, ref.Argon2Memory, uint32(ref.Argon2Threads)}
+	default:
+		return nil, ErrUnknownKeystoreKdf
+	}
+	for i, param := range params {
+		binary.BigEndian.PutUint32(header[len(passwordMagic)+3+4*i:], param)
+	}
+	_, err := io.ReadFull(ref.seed, header[passwordSaltStart:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newPasswordAead(header, ref.password)
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[passwordSaltStart+passwordSaltSize:]
+
+	return aead.Seal(header, nonce, input, aeadAssociatedData(header, associatedData)), nil
+}
+
+// DecryptWithAssociatedData decrypts a ciphertext created with the same password and associatedData.
+// A wrong password returns ErrDecryptionFailed.
+func (ref *PasswordBlockCipher) DecryptWithAssociatedData(input []byte, associatedData []byte) ([]byte, error) {
+
+	if len(input) < passwordSaltStart {
+		return nil, ErrDecryptionFailed
+	}
+	suite := CipherSuite(input[len(passwordMagic)+1])
+	if !bytes.Equal(input[:len(passwordMagic)], passwordMagic) ||
+		input[len(passwordMagic)] != passwordFormatVersion || !suite.isKnown() {
+		return nil, ErrUnsupportedCipherFormat
+	}
+	headerSize := passwordSaltStart + passwordSaltSize + suite.nonceSize()
+	if len(input) < headerSize {
+		return nil, ErrDecryptionFailed
+	}
+
+	header := input[:headerSize]
+	aead, err := newPasswordAead(header, ref.password)
+	if err != nil {
+		return nil, err
+	}
+	if len(input) < headerSize+aead.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+	plaintext, err := aead.Open(nil, header[passwordSaltStart+passwordSaltSize:], input[headerSize:], aeadAssociatedData(header, associatedData))
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+// newPasswordAead derives the key from password with the KDF, parameters and salt of header
+// and creates the AEAD of the suite of header.
+// The parameters are validated first against the bounds of the keystore parameters,
+// so a crafted header cannot exhaust memory.
+func newPasswordAead(header []byte, password []byte) (cipher.AEAD, error) {
+
+	kdf, params, err := passwordKdfParams(header)
+	if err != nil {
+		return nil, err
+	}
+	err = params.validate(kdf)
+	if err != nil {
+		return nil, err
+	}
+	salt := header[passwordSaltStart : passwordSaltStart+passwordSaltSize]
+	key, err := params.deriveKeyWithSalt(kdf, password, salt, passwordKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return CipherSuite(header[len(passwordMagic)+1]).newAead(key)
+}
+
+// passwordKdfParams reads the KDF and its parameters from header.
+func passwordKdfParams(header []byte) (KeystoreKdf, *KeystoreKdfParams, error) {
+
+	var params [3]uint32
+	for i := range params {
+		params[i] = binary.BigEndian.Uint32(header[len(passwordMagic)+3+4*i:])
+	}
+
+	switch header[len(passwordMagic)+2] {
+	case passwordKdfScrypt:
+		if params[0] > math.MaxInt32 || params[1] > math.MaxInt32 || params[2] > math.MaxInt32 {
+			return "", nil, ErrInvalidKeystoreKdfParams
+		}
+		return KeystoreKdfScrypt, &KeystoreKdfParams{N: int(params[0]), R: int(params[1]), P: int(params[2])}, nil
+	case passwordKdfArgon2id:
+		if params[2] > math.MaxUint8 {
+			return "", nil, ErrInvalidKeystoreKdfParams
+		}
+		return KeystoreKdfArgon2id, &KeystoreKdfParams{Time: params[0], Memory: params[1], Threads: uint8(params[2])}, nil
+	}
+
+	return "", nil, ErrUnsupportedCipherFormat
+}
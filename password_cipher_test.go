@@ -0,0 +1,147 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPasswordBlockCipher keeps the KDFs cheap, so the tests run fast
+func newTestPasswordBlockCipher(password []byte, kdf KeystoreKdf) *PasswordBlockCipher {
+
+	blockCipher := NewPasswordBlockCipher(password, nil)
+	blockCipher.Kdf = kdf
+	blockCipher.ScryptN = 1 << 10
+	blockCipher.Argon2Time, blockCipher.Argon2Memory, blockCipher.Argon2Threads = 1, 64, 1
+	return blockCipher
+}
+
+func TestPasswordBlockCipher_RoundTrip(t *testing.T) {
+	var _ AeadBlockCipher = (*PasswordBlockCipher)(nil)
+
+	for _, kdf := range []KeystoreKdf{KeystoreKdfScrypt, KeystoreKdfArgon2id} {
+		for _, suite := range []CipherSuite{CipherSuiteAes256Gcm, CipherSuiteChaCha20Poly1305, CipherSuiteXChaCha20Poly1305} {
+			encrypter := newTestPasswordBlockCipher(keystorePassword, kdf)
+			encrypter.Suite = suite
+
+			encrypted, err := encrypter.Encrypt([]byte(message))
+			assert.Nil(t, err)
+			assert.Len(t, encrypted, passwordSaltStart+passwordSaltSize+suite.nonceSize()+len(message)+16)
+			assert.Equal(t, passwordMagic, encrypted[:len(passwordMagic)])
+			assert.Equal(t, byte(suite), encrypted[len(passwordMagic)+1])
+
+			// the KDF, its parameters and the suite are read from the ciphertext
+			var blockCipher BlockCipher = NewPasswordBlockCipher(keystorePassword, CryptoEngines.Ed25519KeccakEngine)
+			decrypted, err := blockCipher.Decrypt(encrypted)
+			assert.Nil(t, err)
+			assert.Equal(t, message, string(decrypted))
+		}
+	}
+}
+
+func TestPasswordBlockCipher_SaltFromEngineSeed(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x42}, 2*(passwordSaltSize+12))
+	encrypter := NewPasswordBlockCipher(keystorePassword, NewEd25519SeedCryptoEngine(bytes.NewReader(seed)))
+	encrypter.Argon2Time, encrypter.Argon2Memory, encrypter.Argon2Threads = 1, 64, 1
+
+	first, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+	assert.Equal(t, seed[:passwordSaltSize+12], first[passwordSaltStart:passwordSaltStart+passwordSaltSize+12])
+	second, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+
+	_, err = encrypter.Encrypt([]byte(message))
+	assert.NotNil(t, err)
+}
+
+func TestPasswordBlockCipher_WrongPasswordAndTampering(t *testing.T) {
+	encrypter := newTestPasswordBlockCipher(keystorePassword, KeystoreKdfScrypt)
+	encrypted, err := encrypter.EncryptWithAssociatedData([]byte(message), []byte("backup"))
+	assert.Nil(t, err)
+
+	_, err = newTestPasswordBlockCipher([]byte("wrong password"), KeystoreKdfScrypt).DecryptWithAssociatedData(encrypted, []byte("backup"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = encrypter.DecryptWithAssociatedData(encrypted, []byte("other"))
+	assert.Equal(t, ErrDecryptionFailed, err)
+	_, err = encrypter.Decrypt(encrypted)
+	assert.Equal(t, ErrDecryptionFailed, err)
+
+	// the scrypt r is authenticated, the salt, the nonce and the ciphertext too
+	for _, i := range []int{len(passwordMagic) + 10, passwordSaltStart, passwordSaltStart + passwordSaltSize, len(encrypted) - 1} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 0x01
+		_, err = encrypter.DecryptWithAssociatedData(tampered, []byte("backup"))
+		assert.Equalf(t, ErrDecryptionFailed, err, "byte %d", i)
+	}
+
+	for _, size := range []int{0, passwordSaltStart - 1, passwordSaltStart + passwordSaltSize, len(encrypted) - len(message) - 1} {
+		_, err = encrypter.DecryptWithAssociatedData(encrypted[:size], []byte("backup"))
+		assert.Equal(t, ErrDecryptionFailed, err)
+	}
+}
+
+func TestPasswordBlockCipher_Errors(t *testing.T) {
+	encrypter := newTestPasswordBlockCipher(keystorePassword, KeystoreKdfArgon2id)
+	encrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+
+	for _, i := range []int{0, len(passwordMagic), len(passwordMagic) + 1, len(passwordMagic) + 2} {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] = 0xff
+		_, err = encrypter.Decrypt(tampered)
+		assert.Equal(t, ErrUnsupportedCipherFormat, err)
+	}
+
+	// the parameters of a crafted header are bounded before the key is derived
+	tampered := append([]byte{}, encrypted...)
+	binary.BigEndian.PutUint32(tampered[len(passwordMagic)+3+4:], maxKeystoreArgon2Memory+1)
+	_, err = encrypter.Decrypt(tampered)
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+	binary.BigEndian.PutUint32(tampered[len(passwordMagic)+3+8:], 256)
+	_, err = encrypter.Decrypt(tampered)
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+
+	encrypter.Kdf = KeystoreKdf("pbkdf2")
+	_, err = encrypter.Encrypt([]byte(message))
+	assert.Equal(t, ErrUnknownKeystoreKdf, err)
+
+	encrypter.Kdf = KeystoreKdfScrypt
+	encrypter.ScryptN = 1000
+	_, err = encrypter.Encrypt([]byte(message))
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+
+	encrypter.ScryptN = 1 << 10
+	encrypter.Suite = CipherSuite(0)
+	_, err = encrypter.Encrypt([]byte(message))
+	assert.Equal(t, ErrUnknownCipherSuite, err)
+}
+
+func TestPasswordBlockCipher_RejectsHostileKdfParams(t *testing.T) {
+	encrypter := newTestPasswordBlockCipher(keystorePassword, KeystoreKdfScrypt)
+	encrypter.ScryptN, encrypter.ScryptR, encrypter.ScryptP = 2, 1, 1
+	encrypted, err := encrypter.Encrypt([]byte(message))
+	assert.Nil(t, err)
+
+	// scrypt would be asked for 2^49 bytes
+	tampered := append([]byte{}, encrypted...)
+	binary.BigEndian.PutUint32(tampered[len(passwordMagic)+3:], 1<<22)
+	binary.BigEndian.PutUint32(tampered[len(passwordMagic)+3+4:], 1<<20)
+	assert.NotPanics(t, func() {
+		_, err = encrypter.Decrypt(tampered)
+	})
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+
+	tampered[len(passwordMagic)+2] = passwordKdfArgon2id
+	for i, param := range []uint32{maxKeystoreArgon2Time + 1, 64, 1} {
+		binary.BigEndian.PutUint32(tampered[len(passwordMagic)+3+4*i:], param)
+	}
+	_, err = encrypter.Decrypt(tampered)
+	assert.Equal(t, ErrInvalidKeystoreKdfParams, err)
+}
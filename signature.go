@@ -20,6 +20,23 @@ type Signature struct {
 }
 
 var (
+	// ErrMalleableSignature is returned when S is not reduced modulo the group order,
+	// so the same signature could be presented with a different S.
+	ErrMalleableSignature = errors.New("signature is malleable: S is not less than the group order")
+	// ErrInvalidSignature is returned when the signature does not verify.
+	ErrInvalidSignature = errors.New("signature is not valid")
+	// ErrInvalidPublicKey is returned when the public key is not a valid point encoding
+	// or is rejected by the verification policy.
+	ErrInvalidPublicKey = errors.New("public key is not valid for signature verification")
+	// ErrInvalidSignatureR is returned when R is not a valid point encoding.
+	ErrInvalidSignatureR = errors.New("signature R is not a valid point encoding")
+	// ErrSmallOrderSignatureR is returned when R is a point of small order.
+	ErrSmallOrderSignatureR = errors.New("signature R is a point of small order")
+	// ErrSignatureHash is returned when the hash of R, the public key and the message cannot be computed.
+	ErrSignatureHash = errors.New("cannot hash signature data")
+	// ErrUnknownVerificationPolicy is returned when the signer has a verification policy this library does not know.
+	ErrUnknownVerificationPolicy = errors.New("unknown verification policy")
+
 	errBadParamNewSignature          = errors.New("binary signature representation of r and s must both have 32 bytes length")
 	errBadParamNewSignatureBigInt    = errors.New("bad parameters NewSignatureFromBigInt")
 	errBadParamNewSignatureFromBytes = errors.New("binary signature representation must be 64 bytes")
@@ -15,11 +15,10 @@ func NewSigner(signer DsaSigner) *Signer {
 }
 
 // NewSignerFromKeyPair creates a signer around a KeyPair.
+// if engine is nil - use CryptoEngines.Default() instead, which follows SetDefault.
+// The deprecated CryptoEngines.DefaultEngine is always ed25519-sha3, it does not follow SetDefault.
 func NewSignerFromKeyPair(keyPair *KeyPair, engine CryptoEngine) *Signer {
-	if engine == nil {
-		engine = CryptoEngines.DefaultEngine
-	}
-	return NewSigner(engine.CreateDsaSigner(keyPair))
+	return NewSigner(CryptoEngines.resolve(engine).CreateDsaSigner(keyPair))
 }
 
 // Sign implemented interface DsaSigner method
@@ -34,6 +33,12 @@ func (ref *Signer) Verify(data []byte, signature *Signature) bool {
 	return ref.signer.Verify(data, signature)
 }
 
+// VerifyWithError implemented interface DsaSigner method
+func (ref *Signer) VerifyWithError(data []byte, signature *Signature) error {
+
+	return ref.signer.VerifyWithError(data, signature)
+}
+
 // IsCanonicalSignature implemented interface DsaSigner method
 func (ref *Signer) IsCanonicalSignature(signature *Signature) bool {
 
@@ -21,7 +21,7 @@ func init() {
 
 	keyPair, _ = NewRandomKeyPair()
 	contextSignature, _ = NewSignatureFromBigInt(bigIntegerONE(), bigIntegerONE())
-	contextDsaSigner = CryptoEngines.DefaultEngine.CreateDsaSigner(keyPair)
+	contextDsaSigner = CryptoEngines.Default().CreateDsaSigner(keyPair)
 }
 
 func TestNewSignerFromKeyPair(t *testing.T) {
@@ -72,3 +72,14 @@ func TestMakeSignatureCanonicalDelegatesToDsaSigner(t *testing.T) {
 	}
 	assert.Equal(t, contextSignature, signature, " must by canonical")
 }
+
+func TestVerifyWithErrorDelegatesToDsaSigner(t *testing.T) {
+
+	signer := NewSigner(contextDsaSigner)
+	signature, err := signer.Sign(testDataForSigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, signer.VerifyWithError(testDataForSigner, signature))
+	assert.Equal(t, ErrInvalidSignature, signer.VerifyWithError([]byte("other data"), signature))
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// X25519KeySize is the size of X25519 private keys, public keys and shared secrets.
+const X25519KeySize = 32
+
+var (
+	// ErrInvalidX25519Key is returned when an X25519 key is not 32 bytes.
+	ErrInvalidX25519Key = errors.New("X25519 key must be 32 bytes")
+	// ErrUnconvertiblePublicKey is returned when an Ed25519 public key is not a canonical point of large order.
+	ErrUnconvertiblePublicKey = errors.New("public key cannot be converted to X25519")
+	// ErrUnsupportedX25519Engine is returned when the crypto engine does not use an Ed25519 key generator.
+	ErrUnsupportedX25519Engine = errors.New("crypto engine does not support X25519 keys")
+)
+
+// Ed25519PrivateKeyToX25519 converts an Ed25519 private key into the X25519 private key of the same identity:
+// the clamped scalar the engine derives the public key with. With the Ed25519Sha512Engine
+// it is the key libsodium crypto_sign_ed25519_sk_to_curve25519 returns.
+// if crypto engine is nil - default Engine
+func Ed25519PrivateKeyToX25519(privateKey *PrivateKey, engine CryptoEngine) ([]byte, error) {
+
+	generator, ok := CryptoEngines.resolve(engine).CreateKeyGenerator().(*Ed25519KeyGenerator)
+	if !ok {
+		return nil, ErrUnsupportedX25519Engine
+	}
+
+	scalar := generator.schema.prepareForScalarMultiply(privateKey)
+	return scalar.Raw, nil
+}
+
+// Ed25519PublicKeyToX25519 converts an Ed25519 public key into the X25519 public key u = (1 + y) / (1 - y).
+// It does not depend on the engine. Keys that are not canonical or have a small order are rejected.
+func Ed25519PublicKeyToX25519(publicKey *PublicKey) ([]byte, error) {
+
+	encoded, err := NewEd25519EncodedGroupElement(publicKey.Raw)
+	if err != nil {
+		return nil, ErrInvalidSizePublicKey
+	}
+	if !encoded.IsCanonical() {
+		return nil, ErrUnconvertiblePublicKey
+	}
+	point, err := encoded.Decode()
+	if err != nil || point.hasSmallOrder() {
+		return nil, ErrUnconvertiblePublicKey
+	}
+
+	raw := make([]byte, len(publicKey.Raw))
+	copy(raw, publicKey.Raw)
+	raw[len(raw)-1] &= 0x7F
+	y := MathUtils.BytesToBigInteger(raw)
+
+	// y is not 1, the neutral element has a small order
+	p := Ed25519Field.P
+	one := big.NewInt(1)
+	denominator := new(big.Int).Sub(one, y)
+	denominator.Mod(denominator, p).ModInverse(denominator, p)
+	u := new(big.Int).Add(one, y)
+	u.Mul(u, denominator).Mod(u, p)
+
+	return MathUtils.ToEncodedFieldElement(u).Raw, nil
+}
+
+// X25519 returns the X25519 Diffie-Hellman shared secret of privateKey and publicKey.
+// An all-zero shared secret, which a public key of small order produces, is rejected with ErrInvalidSharedSecret.
+func X25519(privateKey []byte, publicKey []byte) ([]byte, error) {
+
+	if len(privateKey) != X25519KeySize || len(publicKey) != X25519KeySize {
+		return nil, ErrInvalidX25519Key
+	}
+
+	var dst, scalar, point [X25519KeySize]byte
+	copy(scalar[:], privateKey)
+	copy(point[:], publicKey)
+	curve25519.ScalarMult(&dst, &scalar, &point)
+
+	if subtle.ConstantTimeCompare(dst[:], make([]byte, X25519KeySize)) == 1 {
+		return nil, ErrInvalidSharedSecret
+	}
+
+	return dst[:], nil
+}
+
+// X25519PrivateKey returns the X25519 private key of the key pair.
+// if crypto engine is nil - default Engine
+func (ref *KeyPair) X25519PrivateKey(engine CryptoEngine) ([]byte, error) {
+
+	return Ed25519PrivateKeyToX25519(ref.PrivateKey, engine)
+}
+
+// X25519PublicKey returns the X25519 public key of the key pair.
+func (ref *KeyPair) X25519PublicKey() ([]byte, error) {
+
+	return Ed25519PublicKeyToX25519(ref.PublicKey)
+}
+
+// X25519SharedSecret returns the X25519 Diffie-Hellman shared secret of the key pair and the Ed25519 publicKey.
+// Both sides compute the same secret. The private key is required.
+// if crypto engine is nil - default Engine
+func (ref *KeyPair) X25519SharedSecret(publicKey *PublicKey, engine CryptoEngine) ([]byte, error) {
+
+	if !ref.HasPrivateKey() {
+		return nil, errors.New("cannot compute shared secret without private key")
+	}
+	privateKey, err := ref.X25519PrivateKey(engine)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := Ed25519PublicKeyToX25519(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return X25519(privateKey, peer)
+}
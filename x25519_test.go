@@ -0,0 +1,113 @@
+// Copyright 2018 ProximaX Limited. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestEd25519ToX25519_Sha512Vector(t *testing.T) {
+	// RFC 8032 test 1, the X25519 keys are the ones of libsodium crypto_sign_ed25519_*_to_curve25519
+	privateKey, err := NewPrivateKeyfromHexString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	assert.Nil(t, err)
+	publicKey, err := NewPublicKeyfromHex("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	assert.Nil(t, err)
+
+	xPrivateKey, err := Ed25519PrivateKeyToX25519(privateKey, CryptoEngines.Ed25519Sha512Engine)
+	assert.Nil(t, err)
+	assert.Equal(t, "307c83864f2833cb427a2ef1c00a013cfdff2768d980c0a3a520f006904de94f", hex.EncodeToString(xPrivateKey))
+
+	xPublicKey, err := Ed25519PublicKeyToX25519(publicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "d85e07ec22b0ad881537c2f44d662d1a143cf830c57aca4305d85c7a90f6b62e", hex.EncodeToString(xPublicKey))
+}
+
+func TestEd25519ToX25519_KeysMatch(t *testing.T) {
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		kp, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+
+		xPrivateKey, err := kp.X25519PrivateKey(engine)
+		assert.Nil(t, err)
+		xPublicKey, err := kp.X25519PublicKey()
+		assert.Nil(t, err)
+
+		var expected, scalar [X25519KeySize]byte
+		copy(scalar[:], xPrivateKey)
+		curve25519.ScalarBaseMult(&expected, &scalar)
+		assert.Equal(t, expected[:], xPublicKey)
+	}
+}
+
+func TestKeyPair_X25519SharedSecret(t *testing.T) {
+	for _, engine := range []CryptoEngine{CryptoEngines.Ed25519Engine, CryptoEngines.Ed25519Sha512Engine, CryptoEngines.Ed25519KeccakEngine} {
+		alice, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+		bob, err := NewKeyPairByEngine(engine)
+		assert.Nil(t, err)
+
+		aliceSecret, err := alice.X25519SharedSecret(bob.PublicKey, engine)
+		assert.Nil(t, err)
+		bobSecret, err := bob.X25519SharedSecret(alice.PublicKey, engine)
+		assert.Nil(t, err)
+		assert.Equal(t, aliceSecret, bobSecret)
+
+		// the peer may hold only X25519 keys
+		bobPrivateKey, err := bob.X25519PrivateKey(engine)
+		assert.Nil(t, err)
+		alicePublicKey, err := alice.X25519PublicKey()
+		assert.Nil(t, err)
+		secret, err := X25519(bobPrivateKey, alicePublicKey)
+		assert.Nil(t, err)
+		assert.Equal(t, aliceSecret, secret)
+	}
+
+	_, err := (&KeyPair{nil, NewPublicKey(make([]byte, 32))}).X25519SharedSecret(NewPublicKey(make([]byte, 32)), nil)
+	assert.NotNil(t, err)
+}
+
+func TestX25519(t *testing.T) {
+	// RFC 7748 section 6.1
+	alicePrivateKey, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	bobPublicKey, _ := hex.DecodeString("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+
+	secret, err := X25519(alicePrivateKey, bobPublicKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742", hex.EncodeToString(secret))
+
+	for _, point := range []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"e0eb7a7c3b41b8ae1656e3faf19fc46ada098deb9c32b1fd866205165f49b800",
+	} {
+		publicKey, _ := hex.DecodeString(point)
+		_, err = X25519(alicePrivateKey, publicKey)
+		assert.Equal(t, ErrInvalidSharedSecret, err, point)
+	}
+
+	_, err = X25519(alicePrivateKey[:31], bobPublicKey)
+	assert.Equal(t, ErrInvalidX25519Key, err)
+}
+
+func TestEd25519PublicKeyToX25519_Rejects(t *testing.T) {
+	for _, encoded := range []string{
+		// neutral element and a point of order 8
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a",
+		// y = p is not canonical
+		"edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	} {
+		raw, _ := hex.DecodeString(encoded)
+		_, err := Ed25519PublicKeyToX25519(NewPublicKey(raw))
+		assert.Equal(t, ErrUnconvertiblePublicKey, err, encoded)
+	}
+
+	_, err := Ed25519PublicKeyToX25519(NewPublicKey(make([]byte, 31)))
+	assert.Equal(t, ErrInvalidSizePublicKey, err)
+}